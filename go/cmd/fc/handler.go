@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"skland-daily-attendance-go/internal/cloudfn"
+	"skland-daily-attendance-go/internal/server"
+)
+
+// maxEventBytes bounds the event of an invocation.
+const maxEventBytes = 64 << 10
+
+// newHandler routes the custom runtime interface: the lifecycle hooks,
+// event invocations and, for everything else, the HTTP trigger. The HTTP
+// trigger passes every path through, /invoke included, and nothing in a
+// request proves it came from the runtime rather than the trigger, so event
+// invocations are checked by auth as well: a function with HTTP credentials
+// accepts only authenticated invocations, and one without, serving events
+// only, accepts every invocation.
+func newHandler(fn *cloudfn.Function, auth *server.Authenticator) http.Handler {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/", fn.Handler(auth))
+	mux.Handle("/invoke", server.LimitBody(auth.Wrap(server.Methods{http.MethodPost: invoke(fn)}), maxEventBytes))
+	mux.Handle("/initialize", server.Methods{http.MethodPost: ok})
+	mux.Handle("/pre-freeze", server.Methods{http.MethodGet: ok})
+	mux.Handle("/pre-stop", server.Methods{http.MethodGet: ok})
+	return mux
+}
+
+// timerEvent is the event of a timer trigger, whose payload is the string
+// configured on the trigger.
+type timerEvent struct {
+	TriggerTime string `json:"triggerTime"`
+	TriggerName string `json:"triggerName"`
+	Payload     string `json:"payload"`
+}
+
+// parseEvent extracts the Request of an event invocation: the payload of a
+// timer trigger, or the event itself when the function is invoked directly.
+func parseEvent(raw []byte) (cloudfn.Request, error) {
+	var timer timerEvent
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		if err := json.Unmarshal(raw, &timer); err != nil {
+			return cloudfn.Request{}, cloudfn.InvalidRequest(err)
+		}
+	}
+	if timer.TriggerName != "" && timer.TriggerTime != "" {
+		return cloudfn.DecodeRequest([]byte(timer.Payload))
+	}
+	return cloudfn.DecodeRequest(raw)
+}
+
+// invoke returns the handler of event invocations, which fn runs. The
+// response body is the result of the invocation; a failed run is reported
+// with the x-fc-status header so that the platform records an unhandled
+// error and retries asynchronous invocations.
+func invoke(fn *cloudfn.Function) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			server.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		resp := cloudfn.Response{Result: "failed"}
+		req, err := parseEvent(raw)
+		if err == nil {
+			resp, err = fn.Handle(r.Context(), req)
+		} else {
+			resp.Error = err.Error()
+		}
+		if err != nil {
+			w.Header().Set("x-fc-status", "404")
+			server.WriteJSON(w, http.StatusNotFound, resp)
+			return
+		}
+		server.WriteJSON(w, http.StatusOK, resp)
+	}
+}
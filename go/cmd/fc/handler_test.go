@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"skland-daily-attendance-go/internal/cloudfn"
+	"skland-daily-attendance-go/internal/cloudfn/cloudfntest"
+	"skland-daily-attendance-go/internal/server"
+)
+
+func serve(h http.Handler, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder) cloudfn.Response {
+	t.Helper()
+	var resp cloudfn.Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", w.Body, err)
+	}
+	return resp
+}
+
+func TestInvoke(t *testing.T) {
+	sk, fn := cloudfntest.New(t)
+	h := newHandler(fn, server.NewAuthenticator([]string{"secret"}, ""))
+
+	tests := []struct {
+		name       string
+		event      string
+		wantStatus int
+		wantDryRun bool
+	}{
+		{name: "empty event", event: "", wantStatus: http.StatusOK},
+		{name: "direct request", event: `{"dryRun":true}`, wantStatus: http.StatusOK, wantDryRun: true},
+		{
+			name:       "timer with payload",
+			event:      `{"triggerTime":"2025-01-01T00:00:00Z","triggerName":"daily","payload":"{\"dryRun\":true}"}`,
+			wantStatus: http.StatusOK,
+			wantDryRun: true,
+		},
+		{
+			name:       "timer without payload",
+			event:      `{"triggerTime":"2025-01-01T00:00:00Z","triggerName":"daily","payload":""}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "timer with invalid payload",
+			event:      `{"triggerTime":"2025-01-01T00:00:00Z","triggerName":"daily","payload":"{\"dry_run\":true}"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{name: "unknown account", event: `{"accounts":["nobody"]}`, wantStatus: http.StatusNotFound},
+		{name: "malformed", event: `{`, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, http.MethodPost, "/invoke", tt.event, map[string]string{"Authorization": "Bearer secret"})
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			resp := decode(t, w)
+			if tt.wantStatus != http.StatusOK {
+				if w.Header().Get("x-fc-status") != "404" {
+					t.Errorf("x-fc-status = %q, want 404", w.Header().Get("x-fc-status"))
+				}
+				if resp.Result != "failed" || resp.Error == "" {
+					t.Errorf("response = %+v, want a failure", resp)
+				}
+				return
+			}
+			if w.Header().Get("x-fc-status") != "" {
+				t.Errorf("x-fc-status = %q on success", w.Header().Get("x-fc-status"))
+			}
+			if resp.Result != "success" || resp.DryRun != tt.wantDryRun {
+				t.Errorf("response = %+v, want success with dryRun %v", resp, tt.wantDryRun)
+			}
+		})
+	}
+	if claims := sk.Claims(); len(claims) != 1 {
+		t.Errorf("claims = %v, want one from the first full run", claims)
+	}
+}
+
+func TestInvokeAuthentication(t *testing.T) {
+	sk, fn := cloudfntest.New(t)
+	h := newHandler(fn, server.NewAuthenticator([]string{"secret"}, ""))
+	// The HTTP trigger forwards /invoke like any other path.
+	w := serve(h, http.MethodPost, "/invoke", "{}", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401: %s", w.Code, w.Body)
+	}
+	if claims := sk.Claims(); len(claims) != 0 {
+		t.Errorf("claims = %v, want none", claims)
+	}
+
+	// Without HTTP credentials the function serves events only.
+	h = newHandler(fn, server.NewAuthenticator(nil, ""))
+	if w := serve(h, http.MethodPost, "/invoke", `{"dryRun":true}`, nil); w.Code != http.StatusOK {
+		t.Errorf("status without credentials = %d, want 200: %s", w.Code, w.Body)
+	}
+}
+
+func TestRuntimeRoutes(t *testing.T) {
+	_, fn := cloudfntest.New(t)
+	h := newHandler(fn, server.NewAuthenticator([]string{"secret"}, ""))
+	bearer := map[string]string{"Authorization": "Bearer secret"}
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		header     map[string]string
+		wantStatus int
+	}{
+		{name: "initialize", method: http.MethodPost, target: "/initialize", wantStatus: http.StatusOK},
+		{name: "pre-freeze", method: http.MethodGet, target: "/pre-freeze", wantStatus: http.StatusOK},
+		{name: "pre-stop", method: http.MethodGet, target: "/pre-stop", wantStatus: http.StatusOK},
+		{name: "invoke by get", method: http.MethodGet, target: "/invoke", header: bearer, wantStatus: http.StatusMethodNotAllowed},
+		{name: "http trigger without credentials", method: http.MethodPost, target: "/attendance", wantStatus: http.StatusUnauthorized},
+		{name: "http trigger get", method: http.MethodGet, target: "/attendance", header: bearer, wantStatus: http.StatusMethodNotAllowed},
+		{name: "http trigger", method: http.MethodPost, target: "/attendance?dryRun=true", header: bearer, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(h, tt.method, tt.target, "", tt.header)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestInvokeBodyLimit(t *testing.T) {
+	_, fn := cloudfntest.New(t)
+	h := newHandler(fn, server.NewAuthenticator([]string{"secret"}, ""))
+	w := serve(h, http.MethodPost, "/invoke", `{"accounts":["`+strings.Repeat("a", maxEventBytes)+`"]}`, nil)
+	if w.Code == http.StatusOK {
+		t.Errorf("oversized event accepted")
+	}
+}
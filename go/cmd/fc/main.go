@@ -0,0 +1,57 @@
+// Command fc runs attendance as an Aliyun Function Compute custom runtime.
+// It serves the runtime's HTTP interface: event invocations arrive as
+// POST /invoke, and for HTTP functions every other request is forwarded
+// as-is from the HTTP trigger.
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"skland-daily-attendance-go/internal/cloudfn"
+	"skland-daily-attendance-go/internal/config"
+	"skland-daily-attendance-go/internal/server"
+)
+
+// shutdownTimeout bounds the wait for an in-flight invocation when the
+// instance is stopped.
+const shutdownTimeout = 30 * time.Second
+
+// listenPort returns the port the runtime forwards invocations to.
+func listenPort() string {
+	for _, key := range []string{"FC_SERVER_PORT", "FC_CUSTOM_LISTEN_PORT"} {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return "9000"
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("加载配置失败", "err", err)
+		os.Exit(1)
+	}
+	fn, err := cloudfn.New(cfg)
+	if err != nil {
+		slog.Error("启动失败", "err", err)
+		os.Exit(1)
+	}
+	srv := server.New(net.JoinHostPort("0.0.0.0", listenPort()), newHandler(fn, cloudfn.NewAuthenticator(cfg)))
+	slog.Info("函数计算自定义运行时已启动", "addr", srv.Addr)
+	err = server.Serve(ctx, srv, "", "", shutdownTimeout)
+	fn.Shutdown()
+	if err != nil {
+		slog.Error("HTTP 服务异常退出", "err", err)
+		os.Exit(1)
+	}
+}
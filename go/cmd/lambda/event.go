@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"skland-daily-attendance-go/internal/cloudfn"
+)
+
+// eventKind is the source of an invocation.
+type eventKind int
+
+const (
+	eventDirect eventKind = iota
+	eventSchedule
+	eventAPIGatewayV1
+	eventAPIGatewayV2
+)
+
+// invocation is a parsed invocation payload.
+type invocation struct {
+	kind eventKind
+	// req is what direct and scheduled invocations run.
+	req cloudfn.Request
+	// http is the request of API Gateway invocations, served like an HTTP
+	// trigger.
+	http *http.Request
+}
+
+// gateway reports whether the invocation came from API Gateway.
+func (inv invocation) gateway() bool {
+	return inv.kind == eventAPIGatewayV1 || inv.kind == eventAPIGatewayV2
+}
+
+// parseEvent recognises the invocation payload. Direct invocations pass a
+// Request and EventBridge events carry one as their detail; API Gateway
+// requests are converted to HTTP requests, which are authenticated and read
+// like those of an HTTP trigger.
+func parseEvent(ctx context.Context, raw json.RawMessage) (invocation, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return invocation{kind: eventDirect}, nil
+	}
+	var probe struct {
+		Version        string          `json:"version"`
+		HTTPMethod     string          `json:"httpMethod"`
+		RequestContext json.RawMessage `json:"requestContext"`
+		Source         string          `json:"source"`
+		DetailType     string          `json:"detail-type"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return invocation{kind: eventDirect}, cloudfn.InvalidRequest(err)
+	}
+
+	switch {
+	case probe.HTTPMethod != "" && probe.RequestContext != nil:
+		inv := invocation{kind: eventAPIGatewayV1}
+		var ev events.APIGatewayProxyRequest
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return inv, cloudfn.InvalidRequest(err)
+		}
+		r, err := gatewayV1Request(ev).HTTP(ctx)
+		inv.http = r
+		return inv, err
+	case probe.Version == "2.0" && probe.RequestContext != nil:
+		inv := invocation{kind: eventAPIGatewayV2}
+		var ev events.APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return inv, cloudfn.InvalidRequest(err)
+		}
+		r, err := gatewayV2Request(ev).HTTP(ctx)
+		inv.http = r
+		return inv, err
+	case probe.Source != "" && probe.DetailType != "":
+		inv := invocation{kind: eventSchedule}
+		var ev events.EventBridgeEvent
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return inv, cloudfn.InvalidRequest(err)
+		}
+		// Scheduled events carry an empty detail; rules with a constant
+		// input may pass a Request instead.
+		req, err := cloudfn.DecodeRequest(ev.Detail)
+		inv.req = req
+		return inv, err
+	default:
+		req, err := cloudfn.DecodeRequest(raw)
+		return invocation{kind: eventDirect, req: req}, err
+	}
+}
+
+// gatewayV1Request is the HTTP request of a REST API proxy event.
+func gatewayV1Request(ev events.APIGatewayProxyRequest) cloudfn.EventRequest {
+	header := make(http.Header)
+	for k, vs := range ev.MultiValueHeaders {
+		for _, v := range vs {
+			header.Add(k, v)
+		}
+	}
+	for k, v := range ev.Headers {
+		if header.Get(k) == "" {
+			header.Set(k, v)
+		}
+	}
+	query := url.Values(ev.MultiValueQueryStringParameters)
+	if len(query) == 0 {
+		query = make(url.Values)
+		for k, v := range ev.QueryStringParameters {
+			query.Set(k, v)
+		}
+	}
+	return cloudfn.EventRequest{
+		Method:        ev.HTTPMethod,
+		Path:          ev.Path,
+		RawQuery:      query.Encode(),
+		Header:        header,
+		Body:          ev.Body,
+		Base64Encoded: ev.IsBase64Encoded,
+		SourceIP:      ev.RequestContext.Identity.SourceIP,
+	}
+}
+
+// gatewayV2Request is the HTTP request of an HTTP API (payload 2.0) event.
+func gatewayV2Request(ev events.APIGatewayV2HTTPRequest) cloudfn.EventRequest {
+	header := make(http.Header)
+	for k, v := range ev.Headers {
+		header.Set(k, v)
+	}
+	return cloudfn.EventRequest{
+		Method:        ev.RequestContext.HTTP.Method,
+		Path:          ev.RawPath,
+		RawQuery:      ev.RawQueryString,
+		Header:        header,
+		Body:          ev.Body,
+		Base64Encoded: ev.IsBase64Encoded,
+		SourceIP:      ev.RequestContext.HTTP.SourceIP,
+	}
+}
+
+// httpResponse wraps resp in the proxy response shape of the event kind.
+func httpResponse(kind eventKind, resp cloudfn.EventResponse) any {
+	if kind == eventAPIGatewayV2 {
+		return events.APIGatewayV2HTTPResponse{StatusCode: resp.StatusCode, Headers: resp.Headers, Body: resp.Body}
+	}
+	return events.APIGatewayProxyResponse{StatusCode: resp.StatusCode, Headers: resp.Headers, Body: resp.Body}
+}
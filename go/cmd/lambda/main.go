@@ -2,42 +2,54 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
 
 	"github.com/aws/aws-lambda-go/lambda"
 
-	"skland-daily-attendance-go/internal/attendance"
+	"skland-daily-attendance-go/internal/cloudfn"
 	"skland-daily-attendance-go/internal/config"
-	"skland-daily-attendance-go/internal/notify"
-	"skland-daily-attendance-go/internal/storage"
+	"skland-daily-attendance-go/internal/server"
 )
 
-type Response struct {
-	Result string                  `json:"result"`
-	Stats  attendance.ExecutionStats `json:"stats"`
+// newHandler returns the handler of direct invocations, EventBridge events
+// and API Gateway requests, which fn runs. API Gateway requests are checked
+// by auth and always get a proxy response carrying the status code, so that
+// errors are not turned into 502s.
+func newHandler(fn *cloudfn.Function, auth *server.Authenticator) func(context.Context, json.RawMessage) (any, error) {
+	gateway := fn.Handler(auth)
+	return func(ctx context.Context, raw json.RawMessage) (any, error) {
+		inv, err := parseEvent(ctx, raw)
+		if inv.gateway() {
+			if err != nil {
+				return httpResponse(inv.kind, cloudfn.EventError(err)), nil
+			}
+			return httpResponse(inv.kind, cloudfn.ServeEvent(gateway, inv.http)), nil
+		}
+
+		resp := cloudfn.Response{Result: "failed"}
+		if err == nil {
+			resp, err = fn.Handle(ctx, inv.req)
+		} else {
+			resp.Error = err.Error()
+		}
+		return resp, err
+	}
 }
 
-func handler(ctx context.Context) (Response, error) {
+func main() {
 	cfg, err := config.Load()
 	if err != nil {
-		return Response{Result: "failed"}, err
+		slog.Error("加载配置失败", "err", err)
+		os.Exit(1)
 	}
-
-	store := storage.NewMemoryStore()
-	notifier := notify.NewWebhookNotifier(cfg.NotificationURLs)
-	svc := attendance.NewService(cfg, store, notifier)
-
-	res, err := svc.Run(ctx)
-	_ = notifier.Push(ctx)
+	fn, err := cloudfn.New(cfg)
 	if err != nil {
-		return Response{Result: "failed", Stats: res.Stats}, err
+		slog.Error("启动失败", "err", err)
+		os.Exit(1)
 	}
-	return Response{
-		Result: res.Result,
-		Stats:  res.Stats,
-	}, nil
+	// Spans are exported after each invocation, as the instance may be
+	// frozen or stopped without notice.
+	lambda.Start(newHandler(fn, cloudfn.NewAuthenticator(cfg)))
 }
-
-func main() {
-	lambda.Start(handler)
-}
-
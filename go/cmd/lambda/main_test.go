@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"skland-daily-attendance-go/internal/cloudfn"
+	"skland-daily-attendance-go/internal/cloudfn/cloudfntest"
+	"skland-daily-attendance-go/internal/server"
+)
+
+func invoke(t *testing.T, h func(context.Context, json.RawMessage) (any, error), event any) (any, error) {
+	t.Helper()
+	raw, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h(context.Background(), raw)
+}
+
+// proxyResult decodes the response body of a proxy response.
+func proxyResult(t *testing.T, out any) (int, map[string]string, cloudfn.Response) {
+	t.Helper()
+	var status int
+	var headers map[string]string
+	var body string
+	switch r := out.(type) {
+	case events.APIGatewayProxyResponse:
+		status, headers, body = r.StatusCode, r.Headers, r.Body
+	case events.APIGatewayV2HTTPResponse:
+		status, headers, body = r.StatusCode, r.Headers, r.Body
+	default:
+		t.Fatalf("response %T is not a proxy response", out)
+	}
+	var resp cloudfn.Response
+	_ = json.Unmarshal([]byte(body), &resp)
+	return status, headers, resp
+}
+
+func v1Event(method, query string, headers map[string]string, body string) events.APIGatewayProxyRequest {
+	ev := events.APIGatewayProxyRequest{
+		HTTPMethod: method,
+		Path:       "/attendance",
+		Headers:    headers,
+		Body:       body,
+	}
+	if query != "" {
+		ev.QueryStringParameters = map[string]string{"dryRun": query}
+	}
+	ev.RequestContext.Identity.SourceIP = "203.0.113.7"
+	return ev
+}
+
+func TestParseEvent(t *testing.T) {
+	v2 := events.APIGatewayV2HTTPRequest{
+		Version:        "2.0",
+		RawPath:        "/attendance",
+		RawQueryString: "dryRun=true",
+		Headers:        map[string]string{"authorization": "Bearer secret"},
+		RequestContext: events.APIGatewayV2HTTPRequestContext{
+			HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: "POST", SourceIP: "203.0.113.8"},
+		},
+	}
+	v1 := v1Event("POST", "true", map[string]string{"Authorization": "Bearer secret"}, "")
+	v1.MultiValueHeaders = map[string][]string{"X-Forwarded-For": {"198.51.100.1", "198.51.100.2"}}
+	malformed := v1Event("POST", "", nil, "%%")
+	malformed.IsBase64Encoded = true
+
+	tests := []struct {
+		name         string
+		event        any
+		wantKind     eventKind
+		wantDryRun   bool
+		wantSourceIP string
+		// wantForwarded is the number of X-Forwarded-For values.
+		wantForwarded int
+		wantErr       bool
+	}{
+		{name: "null", event: nil, wantKind: eventDirect},
+		{name: "direct", event: map[string]any{"dryRun": true}, wantKind: eventDirect, wantDryRun: true},
+		{name: "unknown field", event: map[string]any{"dry_run": true}, wantKind: eventDirect, wantErr: true},
+		{name: "schedule", event: map[string]any{"source": "aws.events", "detail-type": "Scheduled Event", "detail": map[string]any{}}, wantKind: eventSchedule},
+		{name: "schedule with input", event: map[string]any{"source": "aws.events", "detail-type": "Scheduled Event", "detail": map[string]any{"dryRun": true}}, wantKind: eventSchedule, wantDryRun: true},
+		{name: "v1", event: v1, wantKind: eventAPIGatewayV1, wantSourceIP: "203.0.113.7", wantForwarded: 2},
+		{name: "v2", event: v2, wantKind: eventAPIGatewayV2, wantSourceIP: "203.0.113.8"},
+		{name: "v1 malformed body", event: malformed, wantKind: eventAPIGatewayV1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _ := json.Marshal(tt.event)
+			inv, err := parseEvent(context.Background(), raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, cloudfn.ErrInvalidRequest) {
+				t.Errorf("err = %v, want ErrInvalidRequest", err)
+			}
+			if inv.kind != tt.wantKind {
+				t.Errorf("kind = %d, want %d", inv.kind, tt.wantKind)
+			}
+			if inv.req.DryRun != tt.wantDryRun {
+				t.Errorf("dryRun = %v, want %v", inv.req.DryRun, tt.wantDryRun)
+			}
+			if !inv.gateway() || err != nil {
+				return
+			}
+			r := inv.http
+			if r.Method != "POST" || r.URL.Path != "/attendance" || r.URL.Query().Get("dryRun") != "true" {
+				t.Errorf("request = %s %s", r.Method, r.URL)
+			}
+			if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+				t.Errorf("Authorization = %q", got)
+			}
+			if r.RemoteAddr != tt.wantSourceIP {
+				t.Errorf("RemoteAddr = %q, want %q", r.RemoteAddr, tt.wantSourceIP)
+			}
+			if got := r.Header.Values("X-Forwarded-For"); len(got) != tt.wantForwarded {
+				t.Errorf("X-Forwarded-For = %q, want %d values", got, tt.wantForwarded)
+			}
+		})
+	}
+}
+
+// TestGatewayResponse checks the proxy response of each payload version;
+// authentication and options are covered by the cloudfn tests.
+func TestGatewayResponse(t *testing.T) {
+	_, fn := cloudfntest.New(t)
+	h := newHandler(fn, server.NewAuthenticator([]string{"secret"}, ""))
+	bearer := map[string]string{"Authorization": "Bearer secret"}
+	malformed := v1Event("POST", "", bearer, "%%")
+	malformed.IsBase64Encoded = true
+
+	tests := []struct {
+		name       string
+		event      any
+		wantV2     bool
+		wantStatus int
+		wantResult string
+	}{
+		{name: "v1", event: v1Event("POST", "true", bearer, ""), wantStatus: http.StatusOK, wantResult: "success"},
+		{name: "v1 unauthenticated", event: v1Event("POST", "true", nil, ""), wantStatus: http.StatusUnauthorized},
+		{name: "v1 malformed body", event: malformed, wantStatus: http.StatusBadRequest, wantResult: "failed"},
+		{
+			name: "v2",
+			event: events.APIGatewayV2HTTPRequest{
+				Version:        "2.0",
+				RawPath:        "/attendance",
+				RawQueryString: "dryRun=true",
+				Headers:        map[string]string{"authorization": "Bearer secret"},
+				RequestContext: events.APIGatewayV2HTTPRequestContext{
+					HTTP: events.APIGatewayV2HTTPRequestContextHTTPDescription{Method: "POST", SourceIP: "203.0.113.8"},
+				},
+			},
+			wantV2:     true,
+			wantStatus: http.StatusOK,
+			wantResult: "success",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := invoke(t, h, tt.event)
+			if err != nil {
+				t.Fatalf("handler error: %v", err)
+			}
+			if _, v2 := out.(events.APIGatewayV2HTTPResponse); v2 != tt.wantV2 {
+				t.Errorf("response %T, want payload 2.0 %v", out, tt.wantV2)
+			}
+			status, headers, resp := proxyResult(t, out)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%+v)", status, tt.wantStatus, resp)
+			}
+			if ct := headers["Content-Type"]; ct != "application/json" && tt.wantResult != "" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if resp.Result != tt.wantResult {
+				t.Errorf("result = %q, want %q (%s)", resp.Result, tt.wantResult, resp.Error)
+			}
+		})
+	}
+}
+
+func TestDirectInvocation(t *testing.T) {
+	sk, fn := cloudfntest.New(t)
+	h := newHandler(fn, server.NewAuthenticator([]string{"secret"}, ""))
+
+	// Direct and EventBridge invocations are authorized by IAM and need no
+	// credentials.
+	out, err := invoke(t, h, map[string]any{"dryRun": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := out.(cloudfn.Response); resp.Result != "success" || !resp.DryRun {
+		t.Errorf("direct invocation = %+v", resp)
+	}
+	out, err = invoke(t, h, map[string]any{"source": "aws.events", "detail-type": "Scheduled Event", "detail": map[string]any{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := out.(cloudfn.Response); resp.Result != "success" || resp.DryRun {
+		t.Errorf("scheduled invocation = %+v", resp)
+	}
+	if claims := sk.Claims(); len(claims) != 1 {
+		t.Errorf("claims = %v, want one from the scheduled run", claims)
+	}
+
+	if _, err := invoke(t, h, map[string]any{"dry_run": true}); err == nil {
+		t.Error("misspelt option accepted")
+	}
+}
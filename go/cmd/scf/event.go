@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"skland-daily-attendance-go/internal/cloudfn"
+)
+
+// timerEvent is the event of a timer trigger, whose message is the
+// additional information configured on the trigger.
+type timerEvent struct {
+	Type        string `json:"Type"`
+	TriggerName string `json:"TriggerName"`
+	Time        string `json:"Time"`
+	Message     string `json:"Message"`
+}
+
+// apiGatewayEvent is the event of an API Gateway trigger.
+type apiGatewayEvent struct {
+	RequestContext struct {
+		SourceIP string `json:"sourceIp"`
+	} `json:"requestContext"`
+	HTTPMethod      string            `json:"httpMethod"`
+	Path            string            `json:"path"`
+	Headers         map[string]string `json:"headers"`
+	QueryString     map[string]string `json:"queryString"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+}
+
+// apiGatewayResponse is the integration response returned to API Gateway.
+type apiGatewayResponse struct {
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+	StatusCode      int               `json:"statusCode"`
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+}
+
+// invocation is a parsed invocation event.
+type invocation struct {
+	// req is what timer triggers and direct invocations run.
+	req cloudfn.Request
+	// gateway is set for API Gateway events, whose request is http.
+	gateway bool
+	http    *http.Request
+}
+
+// parseEvent recognises the invocation event. Timer events carry a Request
+// as their message and direct invocations pass one as the event; API
+// Gateway requests are converted to HTTP requests, which are authenticated
+// and read like those of a web function.
+func parseEvent(ctx context.Context, raw []byte) (invocation, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		req, err := cloudfn.DecodeRequest(raw)
+		return invocation{req: req}, err
+	}
+	var probe struct {
+		Type           string          `json:"Type"`
+		HTTPMethod     string          `json:"httpMethod"`
+		RequestContext json.RawMessage `json:"requestContext"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return invocation{}, cloudfn.InvalidRequest(err)
+	}
+
+	switch {
+	case probe.HTTPMethod != "" && probe.RequestContext != nil:
+		inv := invocation{gateway: true}
+		var ev apiGatewayEvent
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return inv, cloudfn.InvalidRequest(err)
+		}
+		r, err := gatewayRequest(ev).HTTP(ctx)
+		inv.http = r
+		return inv, err
+	case probe.Type == "Timer":
+		var ev timerEvent
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return invocation{}, cloudfn.InvalidRequest(err)
+		}
+		req, err := cloudfn.DecodeRequest([]byte(ev.Message))
+		return invocation{req: req}, err
+	default:
+		req, err := cloudfn.DecodeRequest(raw)
+		return invocation{req: req}, err
+	}
+}
+
+// gatewayRequest is the HTTP request of an API Gateway event.
+func gatewayRequest(ev apiGatewayEvent) cloudfn.EventRequest {
+	header := make(http.Header)
+	for k, v := range ev.Headers {
+		header.Set(k, v)
+	}
+	query := make(url.Values)
+	for k, v := range ev.QueryString {
+		query.Set(k, v)
+	}
+	return cloudfn.EventRequest{
+		Method:        ev.HTTPMethod,
+		Path:          ev.Path,
+		RawQuery:      query.Encode(),
+		Header:        header,
+		Body:          ev.Body,
+		Base64Encoded: ev.IsBase64Encoded,
+		SourceIP:      ev.RequestContext.SourceIP,
+	}
+}
+
+// handle runs the invocation for event with fn and returns its result and
+// whether it failed. API Gateway requests are served by gateway and always get an
+// integration response carrying the status code, so that errors are not
+// turned into 502s.
+func handle(ctx context.Context, fn *cloudfn.Function, gateway http.Handler, event []byte) ([]byte, bool) {
+	inv, err := parseEvent(ctx, event)
+	if inv.gateway {
+		var resp cloudfn.EventResponse
+		if err == nil {
+			resp = cloudfn.ServeEvent(gateway, inv.http)
+		} else {
+			resp = cloudfn.EventError(err)
+		}
+		out, _ := json.Marshal(apiGatewayResponse{StatusCode: resp.StatusCode, Headers: resp.Headers, Body: resp.Body})
+		return out, false
+	}
+
+	resp := cloudfn.Response{Result: "failed"}
+	if err == nil {
+		resp, err = fn.Handle(ctx, inv.req)
+	} else {
+		resp.Error = err.Error()
+	}
+	body, _ := json.Marshal(resp)
+	return body, err != nil
+}
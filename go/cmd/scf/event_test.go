@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"skland-daily-attendance-go/internal/cloudfn"
+	"skland-daily-attendance-go/internal/cloudfn/cloudfntest"
+	"skland-daily-attendance-go/internal/server"
+)
+
+func gatewayEvent(method string, headers, query map[string]string, body string) []byte {
+	b, _ := json.Marshal(map[string]any{
+		"requestContext": map[string]any{"sourceIp": "203.0.113.7", "stage": "release"},
+		"httpMethod":     method,
+		"path":           "/attendance",
+		"headers":        headers,
+		"queryString":    query,
+		"body":           body,
+	})
+	return b
+}
+
+// TestGatewayResponse checks the integration response of API Gateway
+// events; authentication and options are covered by the cloudfn tests.
+func TestGatewayResponse(t *testing.T) {
+	_, fn := cloudfntest.New(t)
+	gateway := fn.Handler(server.NewAuthenticator([]string{"secret"}, ""))
+	bearer := map[string]string{"authorization": "Bearer secret"}
+	malformed, _ := json.Marshal(map[string]any{
+		"requestContext":  map[string]any{"sourceIp": "203.0.113.7"},
+		"httpMethod":      "POST",
+		"body":            "%%",
+		"isBase64Encoded": true,
+	})
+
+	tests := []struct {
+		name       string
+		event      []byte
+		wantStatus int
+		wantResult string
+	}{
+		{name: "dry run", event: gatewayEvent("POST", bearer, map[string]string{"dryRun": "true"}, ""), wantStatus: http.StatusOK, wantResult: "success"},
+		{name: "unauthenticated", event: gatewayEvent("POST", nil, nil, ""), wantStatus: http.StatusUnauthorized},
+		{name: "malformed body", event: malformed, wantStatus: http.StatusBadRequest, wantResult: "failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, failed := handle(context.Background(), fn, gateway, tt.event)
+			if failed {
+				t.Error("API Gateway invocation reported as failed")
+			}
+			var resp apiGatewayResponse
+			if err := json.Unmarshal(out, &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus || resp.IsBase64Encoded {
+				t.Fatalf("response = %+v, want status %d", resp, tt.wantStatus)
+			}
+			if tt.wantResult == "" {
+				return
+			}
+			if ct := resp.Headers["Content-Type"]; ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			var body cloudfn.Response
+			if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Result != tt.wantResult {
+				t.Errorf("result = %q, want %q (%s)", body.Result, tt.wantResult, body.Error)
+			}
+		})
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		name        string
+		event       string
+		wantGateway bool
+		wantDryRun  bool
+		wantErr     bool
+	}{
+		{name: "empty", event: ""},
+		{name: "direct", event: `{"dryRun":true}`, wantDryRun: true},
+		{name: "timer", event: `{"Type":"Timer","TriggerName":"daily","Time":"2025-01-01T00:00:00Z","Message":"{\"dryRun\":true}"}`, wantDryRun: true},
+		{name: "timer without message", event: `{"Type":"Timer","TriggerName":"daily","Time":"2025-01-01T00:00:00Z","Message":""}`},
+		{name: "timer with invalid message", event: `{"Type":"Timer","TriggerName":"daily","Message":"{\"dry_run\":true}"}`, wantErr: true},
+		{name: "unknown field", event: `{"dry_run":true}`, wantErr: true},
+		{name: "malformed", event: `{`, wantErr: true},
+		{name: "not an object", event: `[]`, wantErr: true},
+		{name: "gateway", event: string(gatewayEvent("POST", nil, nil, "")), wantGateway: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, err := parseEvent(context.Background(), []byte(tt.event))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, cloudfn.ErrInvalidRequest) {
+				t.Errorf("err = %v, want ErrInvalidRequest", err)
+			}
+			if inv.gateway != tt.wantGateway {
+				t.Errorf("gateway = %v, want %v", inv.gateway, tt.wantGateway)
+			}
+			if inv.req.DryRun != tt.wantDryRun {
+				t.Errorf("dryRun = %v, want %v", inv.req.DryRun, tt.wantDryRun)
+			}
+		})
+	}
+}
+
+func TestParseGatewayEvent(t *testing.T) {
+	b, _ := json.Marshal(map[string]any{
+		"requestContext":  map[string]any{"sourceIp": "203.0.113.7"},
+		"httpMethod":      "POST",
+		"path":            "/attendance",
+		"headers":         map[string]string{"authorization": "Bearer secret"},
+		"queryString":     map[string]string{"dryRun": "true"},
+		"body":            base64.StdEncoding.EncodeToString([]byte(`{"games":["arknights"]}`)),
+		"isBase64Encoded": true,
+	})
+	inv, err := parseEvent(context.Background(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := inv.http
+	if !inv.gateway || r == nil {
+		t.Fatalf("invocation = %+v, want an API Gateway request", inv)
+	}
+	if r.Method != "POST" || r.URL.Path != "/attendance" || r.URL.Query().Get("dryRun") != "true" {
+		t.Errorf("request = %s %s", r.Method, r.URL)
+	}
+	if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if r.RemoteAddr != "203.0.113.7" {
+		t.Errorf("RemoteAddr = %q", r.RemoteAddr)
+	}
+	body, _ := io.ReadAll(r.Body)
+	if string(body) != `{"games":["arknights"]}` {
+		t.Errorf("body = %q, want it base64-decoded", body)
+	}
+}
+
+func TestHandleTimer(t *testing.T) {
+	sk, fn := cloudfntest.New(t)
+	event := []byte(`{"Type":"Timer","TriggerName":"daily","Message":"{\"games\":[\"arknights\"]}"}`)
+	out, failed := handle(context.Background(), fn, nil, event)
+	if failed {
+		t.Fatalf("timer invocation failed: %s", out)
+	}
+	var resp cloudfn.Response
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result != "success" {
+		t.Errorf("response = %+v", resp)
+	}
+	if claims := sk.Claims(); len(claims) != 1 {
+		t.Errorf("claims = %v, want one", claims)
+	}
+
+	out, failed = handle(context.Background(), fn, nil, []byte(`{"accounts":["nobody"]}`))
+	if !failed {
+		t.Errorf("unknown account reported as success: %s", out)
+	}
+}
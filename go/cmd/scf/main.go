@@ -0,0 +1,60 @@
+// Command scf runs attendance on Tencent Cloud Serverless Cloud Function.
+// Started by scf_bootstrap, it runs as an event function through the custom
+// runtime API when SCF_RUNTIME_API is set, and otherwise as a web function
+// serving HTTP requests on port 9000.
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"skland-daily-attendance-go/internal/cloudfn"
+	"skland-daily-attendance-go/internal/config"
+	"skland-daily-attendance-go/internal/server"
+)
+
+const (
+	// webPort is the port web functions must listen on.
+	webPort = "9000"
+	// shutdownTimeout bounds the wait for an in-flight request when the
+	// instance is stopped.
+	shutdownTimeout = 30 * time.Second
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if host := os.Getenv("SCF_RUNTIME_API"); host != "" {
+		rt := newRuntime(host, os.Getenv("SCF_RUNTIME_API_PORT"))
+		if err := rt.serve(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("云函数运行时异常退出", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("加载配置失败", "err", err)
+		os.Exit(1)
+	}
+	fn, err := cloudfn.New(cfg)
+	if err != nil {
+		slog.Error("启动失败", "err", err)
+		os.Exit(1)
+	}
+	srv := server.New(net.JoinHostPort("0.0.0.0", webPort), fn.Handler(cloudfn.NewAuthenticator(cfg)))
+	slog.Info("Web 函数已启动", "addr", srv.Addr)
+	err = server.Serve(ctx, srv, "", "", shutdownTimeout)
+	fn.Shutdown()
+	if err != nil {
+		slog.Error("HTTP 服务异常退出", "err", err)
+		os.Exit(1)
+	}
+}
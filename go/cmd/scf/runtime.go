@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"skland-daily-attendance-go/internal/cloudfn"
+	"skland-daily-attendance-go/internal/config"
+	"skland-daily-attendance-go/internal/skland"
+)
+
+// runtime is a client of the SCF custom runtime API, which hands out
+// invocations one at a time and collects their results.
+type runtime struct {
+	base   string
+	client *http.Client
+	// fn runs invocations and gateway serves API Gateway events, both set
+	// up by serve.
+	fn      *cloudfn.Function
+	gateway http.Handler
+	// clientOpts configure the Skland clients of fn.
+	clientOpts []skland.Option
+}
+
+func newRuntime(host, port string) *runtime {
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+	// No client timeout: fetching the next invocation blocks until there
+	// is one.
+	return &runtime{base: "http://" + host, client: &http.Client{}}
+}
+
+// serve reports the runtime ready and handles invocations until ctx is
+// cancelled. An invalid configuration is reported as an initialization
+// error, as no invocation could succeed.
+func (rt *runtime) serve(ctx context.Context) error {
+	cfg, err := config.Load()
+	if err == nil {
+		rt.fn, err = cloudfn.New(cfg, rt.clientOpts...)
+	}
+	if err != nil {
+		if postErr := rt.post(ctx, "/runtime/init/error", []byte(err.Error())); postErr != nil {
+			return postErr
+		}
+		return err
+	}
+	defer rt.fn.Shutdown()
+	rt.gateway = rt.fn.Handler(cloudfn.NewAuthenticator(cfg))
+	if err := rt.post(ctx, "/runtime/init/ready", nil); err != nil {
+		return err
+	}
+	for ctx.Err() == nil {
+		if err := rt.next(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// next handles a single invocation, bounded by its time limit.
+func (rt *runtime) next(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rt.base+"/runtime/invocation/next", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := rt.client.Do(req)
+	if err != nil {
+		return err
+	}
+	event, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("runtime API: next invocation: %s", resp.Status)
+	}
+
+	invokeCtx := ctx
+	if ms, err := strconv.Atoi(resp.Header.Get("time_limit_in_ms")); err == nil && ms > 0 {
+		var cancel context.CancelFunc
+		invokeCtx, cancel = context.WithTimeout(ctx, time.Duration(ms)*time.Millisecond)
+		defer cancel()
+	}
+	result, failed := handle(invokeCtx, rt.fn, rt.gateway, event)
+	path := "/runtime/invocation/response"
+	if failed {
+		path = "/runtime/invocation/error"
+	}
+	return rt.post(ctx, path, result)
+}
+
+func (rt *runtime) post(ctx context.Context, path string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rt.base+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := rt.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("runtime API: %s: %s", path, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"skland-daily-attendance-go/internal/cloudfn"
+	"skland-daily-attendance-go/internal/cloudfn/cloudfntest"
+	"skland-daily-attendance-go/internal/skland"
+	"skland-daily-attendance-go/internal/skland/sklandtest"
+)
+
+// runtimeAPI fakes the SCF custom runtime API, handing out queued events
+// and recording what the runtime posts back.
+type runtimeAPI struct {
+	events chan string
+
+	mu        sync.Mutex
+	ready     bool
+	initError string
+	results   []result
+	done      chan struct{}
+	want      int
+}
+
+type result struct {
+	path string
+	body string
+}
+
+func newRuntimeAPI(t *testing.T, sk *sklandtest.Server, events ...string) (*runtimeAPI, *runtime) {
+	t.Helper()
+	api := &runtimeAPI{events: make(chan string, len(events)), done: make(chan struct{}), want: len(events)}
+	for _, e := range events {
+		api.events <- e
+	}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := newRuntime(host, port)
+	rt.clientOpts = []skland.Option{sk.Option()}
+	return api, rt
+}
+
+func (api *runtimeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	switch r.Method + " " + r.URL.Path {
+	case "POST /runtime/init/ready":
+		api.mu.Lock()
+		api.ready = true
+		api.mu.Unlock()
+	case "POST /runtime/init/error":
+		api.mu.Lock()
+		api.initError = string(body)
+		api.mu.Unlock()
+	case "GET /runtime/invocation/next":
+		select {
+		case e := <-api.events:
+			w.Header().Set("time_limit_in_ms", "5000")
+			_, _ = io.WriteString(w, e)
+		case <-r.Context().Done():
+		}
+	case "POST /runtime/invocation/response", "POST /runtime/invocation/error":
+		api.mu.Lock()
+		api.results = append(api.results, result{path: r.URL.Path, body: string(body)})
+		if len(api.results) == api.want {
+			close(api.done)
+		}
+		api.mu.Unlock()
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestRuntimeServe(t *testing.T) {
+	sk, _ := cloudfntest.New(t)
+	t.Setenv("HTTP_AUTH_TOKENS", "secret")
+	api, rt := newRuntimeAPI(t, sk,
+		`{"Type":"Timer","TriggerName":"daily","Message":"{\"dryRun\":true}"}`,
+		`{"accounts":["nobody"]}`,
+		string(gatewayEvent("POST", map[string]string{"authorization": "Bearer secret"}, nil, "")),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- rt.serve(ctx) }()
+	select {
+	case <-api.done:
+	case err := <-errc:
+		t.Fatalf("serve returned early: %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for invocation results")
+	}
+	cancel()
+	if err := <-errc; err != nil && ctx.Err() == nil {
+		t.Fatalf("serve: %v", err)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if !api.ready {
+		t.Error("runtime did not report ready")
+	}
+	want := []string{"/runtime/invocation/response", "/runtime/invocation/error", "/runtime/invocation/response"}
+	for i, r := range api.results {
+		if r.path != want[i] {
+			t.Errorf("result %d posted to %s, want %s: %s", i, r.path, want[i], r.body)
+		}
+	}
+
+	var timer cloudfn.Response
+	if err := json.Unmarshal([]byte(api.results[0].body), &timer); err != nil {
+		t.Fatal(err)
+	}
+	if timer.Result != "success" || !timer.DryRun {
+		t.Errorf("timer response = %+v, want a successful dry run", timer)
+	}
+	var gw apiGatewayResponse
+	if err := json.Unmarshal([]byte(api.results[2].body), &gw); err != nil {
+		t.Fatal(err)
+	}
+	if gw.StatusCode != http.StatusOK {
+		t.Errorf("gateway status = %d: %s", gw.StatusCode, gw.Body)
+	}
+	if claims := sk.Claims(); len(claims) != 1 {
+		t.Errorf("claims = %v, want one from the gateway run", claims)
+	}
+}
+
+func TestRuntimeInitError(t *testing.T) {
+	sk, _ := cloudfntest.New(t)
+	t.Setenv("HTTP_RATE_LIMIT", "abc")
+	api, rt := newRuntimeAPI(t, sk)
+
+	if err := rt.serve(context.Background()); err == nil {
+		t.Fatal("serve succeeded with an invalid configuration")
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.ready {
+		t.Error("runtime reported ready with an invalid configuration")
+	}
+	if !strings.Contains(api.initError, "HTTP_RATE_LIMIT") {
+		t.Errorf("init error = %q, want it to name HTTP_RATE_LIMIT", api.initError)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"skland-daily-attendance-go/internal/attendance"
+	"skland-daily-attendance-go/internal/ghactions"
+)
+
+// reportActions publishes the outcome of a run to GitHub Actions when
+// running in a workflow: a Markdown step summary, an error annotation per
+// failed account and step outputs with the result and counts.
+func reportActions(out attendance.Outcome) {
+	gh := ghactions.Detect()
+	if gh == nil {
+		return
+	}
+	r := out.Report()
+
+	var summary strings.Builder
+	if err := r.WriteMarkdown(&summary); err == nil {
+		if err := gh.AppendSummary(summary.String()); err != nil {
+			slog.Warn("写入 GitHub Actions 摘要失败", "err", err)
+		}
+	}
+
+	if r.Error != "" {
+		gh.Error("签到执行出错", r.Error)
+	}
+	accounts := map[string]int{}
+	for _, a := range r.Accounts {
+		accounts[a.Result]++
+		if a.Result != "failed" {
+			continue
+		}
+		reasons := append([]string(nil), a.Errors...)
+		for _, c := range a.Characters {
+			if c.Result == attendance.CharacterFailed {
+				reasons = append(reasons, c.Error)
+			}
+		}
+		gh.Error("账号 "+a.Label+" 签到失败", strings.Join(reasons, "\n"))
+	}
+	if out.NotifyErr != nil {
+		gh.Warning("推送通知失败", out.NotifyErr.Error())
+	}
+
+	characters := r.Counts()
+	outputs := map[string]string{
+		"result":                      r.Result,
+		"run_id":                      r.RunID,
+		"accounts_total":              strconv.Itoa(len(r.Accounts)),
+		"accounts_successful":         strconv.Itoa(accounts["success"]),
+		"accounts_skipped":            strconv.Itoa(accounts["skipped"]),
+		"accounts_failed":             strconv.Itoa(accounts["failed"]),
+		"characters_signed":           strconv.Itoa(characters[attendance.CharacterSuccess]),
+		"characters_already_attended": strconv.Itoa(characters[attendance.CharacterAlreadyAttended]),
+		"characters_would_sign":       strconv.Itoa(characters[attendance.CharacterWouldSign]),
+		"characters_failed":           strconv.Itoa(characters[attendance.CharacterFailed]),
+	}
+	if err := gh.SetOutputs(outputs); err != nil {
+		slog.Warn("写入 GitHub Actions 输出失败", "err", err)
+	}
+}
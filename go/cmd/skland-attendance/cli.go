@@ -0,0 +1,567 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"skland-daily-attendance-go/internal/attendance"
+	"skland-daily-attendance-go/internal/config"
+	"skland-daily-attendance-go/internal/ghactions"
+	"skland-daily-attendance-go/internal/notify"
+	"skland-daily-attendance-go/internal/qinglong"
+	"skland-daily-attendance-go/internal/skland"
+	"skland-daily-attendance-go/internal/storage"
+)
+
+// commandTimeout bounds the commands that call the Skland API or push
+// notifications directly.
+const commandTimeout = 2 * time.Minute
+
+// command is a subcommand of the CLI. run returns the exit status.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// commandList is in the order shown by usage.
+var commandList = []command{
+	{"run", "执行签到，可只处理部分账号或游戏，或仅演练", cmdRun},
+	{"verify", "检查每个 TOKEN 能否登录并显示过期时间", cmdVerify},
+	{"bindings", "列出每个账号绑定的角色", cmdBindings},
+	{"history", "查看保存的执行记录 (需要 STORE_PATH)", cmdHistory},
+	{"notify-test", "向每个通知目标发送一条测试通知", cmdNotifyTest},
+	{"ql-update-token", "将账号的新 TOKEN 写回青龙面板的环境变量", cmdQLUpdateToken},
+}
+
+var commands = map[string]command{}
+
+func init() {
+	for _, c := range commandList {
+		commands[c.name] = c
+	}
+}
+
+// usage prints the subcommands and the legacy flags.
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "用法: %s <命令> [参数]\n\n命令:\n", os.Args[0])
+	for _, c := range commandList {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\n使用 \"%s <命令> -h\" 查看命令参数。不带命令时按 -mode 运行:\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+// newFlagSet creates the flag set of a subcommand.
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: %s %s %s\n", os.Args[0], name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// commandContext returns a context cancelled on SIGINT or SIGTERM and after
+// commandTimeout.
+func commandContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// splitList splits a comma separated flag value.
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// checkFormat validates the value of a --format flag.
+func checkFormat(format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("未知输出格式 %q，可选 table 或 json", format)
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func cmdRun(args []string) int {
+	fs := newFlagSet("run", "[--account 账号] [--game 游戏] [--dry-run] [--force] [--output json|junit|markdown] [--report-file 文件]")
+	accounts := fs.String("account", "", "只处理这些账号，账号名称或序号，逗号分隔")
+	games := fs.String("game", "", "只签到这些游戏，如 arknights、endfield 或游戏名，逗号分隔")
+	dryRun := fs.Bool("dry-run", false, "只检查账号与角色，不签到、不记录、不推送通知")
+	force := fs.Bool("force", false, "忽略“今日已签到”记录，重新检查每个账号")
+	output := fs.String("output", "", "输出执行报告: json | junit | markdown (默认按 --report-file 的扩展名判断)")
+	reportFile := fs.String("report-file", "", "报告写入的文件，不设置时输出到标准输出")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	format := *output
+	if format == "" && *reportFile != "" {
+		format = attendance.ReportFormatForPath(*reportFile)
+	}
+	if format != "" {
+		// Validate the format before running rather than after.
+		if err := attendance.WriteReport(io.Discard, format, &attendance.RunReport{}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		slog.Error("启动失败", "err", err)
+		return 1
+	}
+	opts := attendance.RunOptions{
+		Accounts: splitList(*accounts),
+		Games:    splitList(*games),
+		DryRun:   *dryRun,
+		Force:    *force,
+	}
+	if _, err := attendance.SelectAccounts(cfg, opts.Accounts); err != nil {
+		slog.Error("参数错误", "err", err)
+		return 2
+	}
+	a, err := newApp(cfg)
+	if err != nil {
+		slog.Error("启动失败", "err", err)
+		return 1
+	}
+	defer a.shutdown()
+
+	var out attendance.Outcome
+	if opts.DryRun {
+		// A dry run has no side effects: no lock, history or notifications.
+		ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+		defer cancel()
+		out.RunID = attendance.NewRunID()
+		out.Result, out.Err = a.svc.RunWith(ctx, nil, opts)
+		if out.Err != nil {
+			slog.Error("演练出错", "err", out.Err)
+		}
+	} else {
+		// The coordinator logs the outcome.
+		out = a.coord.RunWith(context.Background(), opts)
+	}
+
+	reportActions(out)
+	code := a.exitCode(out)
+	if format != "" {
+		if err := writeReport(*reportFile, format, out.Report()); err != nil {
+			slog.Error("写入执行报告失败", "err", err)
+			return 1
+		}
+	}
+	return code
+}
+
+// writeReport writes the report to path, or to stdout when path is empty.
+func writeReport(path, format string, r *attendance.RunReport) error {
+	if path == "" {
+		return attendance.WriteReport(os.Stdout, format, r)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := attendance.WriteReport(f, format, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// verifyResult is the outcome of checking a single token.
+type verifyResult struct {
+	Account int        `json:"account"`
+	Label   string     `json:"label"`
+	Valid   bool       `json:"valid"`
+	Expires *time.Time `json:"expires,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+func cmdVerify(args []string) int {
+	fs := newFlagSet("verify", "[--account 账号] [--format table|json]")
+	accounts := fs.String("account", "", "只检查这些账号，账号名称或序号，逗号分隔")
+	format := fs.String("format", "table", "输出格式: table | json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := checkFormat(*format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	cfg, indexes, code := loadAccounts(*accounts)
+	if cfg == nil {
+		return code
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	client := skland.NewClient()
+	var results []verifyResult
+	status := 0
+	for _, idx := range indexes {
+		r := verifyResult{Account: idx + 1, Label: cfg.AccountLabel(idx)}
+		sessionToken, err := signIn(ctx, client, cfg.Tokens[idx])
+		if err != nil {
+			r.Error = err.Error()
+			status = 1
+		} else {
+			r.Valid = true
+			// The session token is preferred: it expires first.
+			if exp, ok := skland.TokenExpiry(sessionToken); ok {
+				r.Expires = &exp
+			} else if exp, ok := skland.TokenExpiry(cfg.Tokens[idx]); ok {
+				r.Expires = &exp
+			}
+		}
+		results = append(results, r)
+	}
+
+	if *format == "json" {
+		_ = writeJSON(os.Stdout, results)
+		return status
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "序号\t账号\t状态\t过期时间\t说明")
+	for _, r := range results {
+		state, expires := "有效", "未知"
+		if !r.Valid {
+			state, expires = "无效", "-"
+		}
+		if r.Expires != nil {
+			expires = r.Expires.In(cfg.Location).Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", r.Account, r.Label, state, expires, r.Error)
+	}
+	_ = tw.Flush()
+	return status
+}
+
+// accountBindings lists the characters bound to a single account.
+type accountBindings struct {
+	Account    int         `json:"account"`
+	Label      string      `json:"label"`
+	Characters []character `json:"characters"`
+	Error      string      `json:"error,omitempty"`
+}
+
+type character struct {
+	AppCode  string `json:"appCode"`
+	GameName string `json:"gameName"`
+	UID      string `json:"uid"`
+	NickName string `json:"nickName,omitempty"`
+	ServerID string `json:"serverId,omitempty"`
+}
+
+func cmdBindings(args []string) int {
+	fs := newFlagSet("bindings", "[--account 账号] [--format table|json]")
+	accounts := fs.String("account", "", "只列出这些账号，账号名称或序号，逗号分隔")
+	format := fs.String("format", "table", "输出格式: table | json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := checkFormat(*format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	cfg, indexes, code := loadAccounts(*accounts)
+	if cfg == nil {
+		return code
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	client := skland.NewClient()
+	var results []accountBindings
+	status := 0
+	for _, idx := range indexes {
+		r := accountBindings{Account: idx + 1, Label: cfg.AccountLabel(idx), Characters: []character{}}
+		items, err := bindings(ctx, client, cfg.Tokens[idx])
+		if err != nil {
+			r.Error = err.Error()
+			status = 1
+		}
+		for _, item := range items {
+			for _, p := range item.BindingList {
+				ch := character{AppCode: item.AppCode, GameName: p.GameName, UID: p.UID}
+				if p.DefaultRole != nil {
+					ch.NickName = p.DefaultRole.NickName
+					ch.ServerID = p.DefaultRole.ServerID
+				}
+				r.Characters = append(r.Characters, ch)
+			}
+		}
+		results = append(results, r)
+	}
+
+	if *format == "json" {
+		_ = writeJSON(os.Stdout, results)
+		return status
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "序号\t账号\t游戏\t角色\tUID\t服务器")
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Fprintf(tw, "%d\t%s\t获取失败: %s\t\t\t\n", r.Account, r.Label, r.Error)
+			continue
+		}
+		if len(r.Characters) == 0 {
+			fmt.Fprintf(tw, "%d\t%s\t(无绑定角色)\t\t\t\n", r.Account, r.Label)
+		}
+		for _, ch := range r.Characters {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", r.Account, r.Label, ch.GameName, ch.NickName, ch.UID, ch.ServerID)
+		}
+	}
+	_ = tw.Flush()
+	return status
+}
+
+func cmdHistory(args []string) int {
+	fs := newFlagSet("history", "[--limit N] [--format table|json] [执行 ID]")
+	limit := fs.Int("limit", defaultRunsLimit, "最多显示的记录数")
+	format := fs.String("format", "table", "输出格式: table | json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := checkFormat(*format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		slog.Error("启动失败", "err", err)
+		return 1
+	}
+	if cfg.StorePath == "" {
+		fmt.Fprintln(os.Stderr, "未设置 STORE_PATH，执行记录只保存在进程内存中")
+		return 1
+	}
+	store, err := storage.Open(cfg.StorePath)
+	if err != nil {
+		slog.Error("打开存储失败", "err", err)
+		return 1
+	}
+	history := attendance.NewHistory(store, historyLimit)
+
+	if id := fs.Arg(0); id != "" {
+		rec, ok, err := history.Get(id)
+		if err != nil {
+			slog.Error("读取执行记录失败", "err", err)
+			return 1
+		}
+		if !ok {
+			fmt.Fprintf(os.Stderr, "执行记录 %s 不存在\n", id)
+			return 1
+		}
+		_ = writeJSON(os.Stdout, rec)
+		return 0
+	}
+
+	records, err := history.List(*limit)
+	if err != nil {
+		slog.Error("读取执行记录失败", "err", err)
+		return 1
+	}
+	if *format == "json" {
+		if records == nil {
+			records = []attendance.RunRecord{}
+		}
+		_ = writeJSON(os.Stdout, records)
+		return 0
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\t状态\t开始时间\t耗时\t成功\t跳过\t失败")
+	for _, rec := range records {
+		duration := "-"
+		if rec.FinishedAt != nil {
+			duration = rec.FinishedAt.Sub(rec.StartedAt).Round(time.Second).String()
+		}
+		var ok, skipped, failed string
+		if rec.Result != nil {
+			acc := rec.Result.Stats.Accounts
+			ok, skipped, failed = fmt.Sprint(acc.Successful), fmt.Sprint(acc.Skipped), fmt.Sprint(acc.Failed)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", rec.ID, rec.Status,
+			rec.StartedAt.In(cfg.Location).Format(time.DateTime), duration, ok, skipped, failed)
+	}
+	_ = tw.Flush()
+	return 0
+}
+
+func cmdNotifyTest(args []string) int {
+	fs := newFlagSet("notify-test", "")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		slog.Error("启动失败", "err", err)
+		return 1
+	}
+	// Without a store, quiet hours and on-change state are left untouched.
+	notifier, err := notify.New(cfg, nil)
+	if err != nil {
+		slog.Error("加载通知配置失败", "err", err)
+		return 1
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	results := notifier.SendTest(ctx)
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stderr, "未配置任何通知目标")
+		return 1
+	}
+	status := 0
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "目标\t结果")
+	for _, r := range results {
+		result := "成功"
+		if r.Err != nil {
+			result = "失败: " + r.Err.Error()
+			status = 1
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", r.Target, result)
+	}
+	_ = tw.Flush()
+	return status
+}
+
+func cmdQLUpdateToken(args []string) int {
+	fs := newFlagSet("ql-update-token", "--account 账号 [--env 变量名] [--skip-verify] <新 TOKEN>")
+	account := fs.String("account", "", "要更新的账号，账号名称或序号")
+	envName := fs.String("env", "SKLAND_TOKENS", "保存 TOKEN 的青龙环境变量")
+	skipVerify := fs.Bool("skip-verify", false, "写回前不检查新 TOKEN 能否登录")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	newToken := strings.TrimSpace(fs.Arg(0))
+	if *account == "" || newToken == "" {
+		fs.Usage()
+		return 2
+	}
+	cfg, indexes, code := loadAccounts(*account)
+	if cfg == nil {
+		return code
+	}
+	if len(indexes) != 1 {
+		fmt.Fprintln(os.Stderr, "--account 只能指定一个账号")
+		return 2
+	}
+	if !cfg.QingLong.Enabled() {
+		fmt.Fprintln(os.Stderr, "需要设置 QL_URL（在青龙面板内运行时可省略）、QL_CLIENT_ID 与 QL_CLIENT_SECRET")
+		return 1
+	}
+	if gh := ghactions.Detect(); gh != nil {
+		gh.Mask(newToken)
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	if !*skipVerify {
+		if _, err := signIn(ctx, skland.NewClient(), newToken); err != nil {
+			slog.Error("新 TOKEN 无法登录，未写回", "err", err)
+			return 1
+		}
+	}
+
+	idx := indexes[0]
+	ql := qinglong.NewClient(cfg.QingLong.URL, cfg.QingLong.ClientID, cfg.QingLong.ClientSecret)
+	envs, err := ql.Envs(ctx, *envName)
+	if err != nil {
+		slog.Error("读取青龙环境变量失败", "err", err)
+		return 1
+	}
+	for _, env := range envs {
+		if env.Name != *envName {
+			continue
+		}
+		value, ok := qinglong.ReplaceToken(env.Value, cfg.Tokens[idx], newToken)
+		if !ok {
+			continue
+		}
+		env.Value = value
+		if err := ql.UpdateEnv(ctx, env); err != nil {
+			slog.Error("写回青龙环境变量失败", "err", err)
+			return 1
+		}
+		fmt.Printf("已更新 %s 中 %s 的 TOKEN\n", *envName, cfg.AccountLabel(idx))
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "青龙环境变量 %s 中没有 %s 当前的 TOKEN\n", *envName, cfg.AccountLabel(idx))
+	return 1
+}
+
+// loadAccounts loads the configuration and selects the accounts named by
+// the --account flag. On failure it returns a nil config and the exit
+// status.
+func loadAccounts(accounts string) (*config.Config, []int, int) {
+	cfg, err := loadConfig()
+	if err != nil {
+		slog.Error("启动失败", "err", err)
+		return nil, nil, 1
+	}
+	if len(cfg.Tokens) == 0 {
+		fmt.Fprintln(os.Stderr, "未配置任何账号 (TOKENS)")
+		return nil, nil, 1
+	}
+	indexes, err := attendance.SelectAccounts(cfg, splitList(accounts))
+	if err != nil {
+		slog.Error("参数错误", "err", err)
+		return nil, nil, 2
+	}
+	return cfg, indexes, 0
+}
+
+// signIn exchanges an account token for a session token.
+func signIn(ctx context.Context, client *skland.Client, token string) (string, error) {
+	code, err := client.GrantAuthorizeCode(ctx, token)
+	if err != nil {
+		return "", fmt.Errorf("获取授权码失败: %w", err)
+	}
+	sessionToken, err := client.SignIn(ctx, code)
+	if err != nil {
+		return "", fmt.Errorf("登录失败: %w", err)
+	}
+	return sessionToken, nil
+}
+
+// bindings returns the characters bound to the account of token.
+func bindings(ctx context.Context, client *skland.Client, token string) ([]skland.BindingItem, error) {
+	sessionToken, err := signIn(ctx, client, token)
+	if err != nil {
+		return nil, err
+	}
+	items, err := client.GetBinding(ctx, sessionToken)
+	if err != nil {
+		return nil, fmt.Errorf("获取绑定角色失败: %w", err)
+	}
+	return items, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"skland-daily-attendance-go/internal/scheduler"
+)
+
+// flushInterval is how often reports deferred by quiet hours are retried.
+const flushInterval = 5 * time.Minute
+
+// runDaemon runs attendance on the configured cron schedule until SIGINT or
+// SIGTERM, letting an in-flight run finish before returning. The HTTP
+// endpoints are served alongside when opts.addr is set.
+func (a *app) runDaemon(opts httpOptions) {
+	cfg := a.cfg
+	schedule, err := scheduler.Parse(cfg.Schedule)
+	if err != nil {
+		fatal("解析 SCHEDULE 失败", "err", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	a.scheduler = scheduler.New(schedule, func(ctx context.Context, run scheduler.Run) {
+		if run.CatchUp {
+			slog.Info("补执行错过的定时任务", "scheduled", run.Scheduled)
+		}
+		// The coordinator logs the outcome.
+		a.coord.Run(ctx)
+	}, scheduler.Options{
+		Location: cfg.Location,
+		Jitter:   cfg.ScheduleJitter,
+		Store:    a.store,
+	})
+
+	go func() {
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := a.notifier.FlushDeferred(ctx); err != nil {
+					slog.Warn("推送延迟通知失败", "err", err)
+				}
+			}
+		}
+	}()
+
+	if cfg.StorePath == "" {
+		slog.Warn("未设置 STORE_PATH，重启后不会补执行错过的任务")
+	}
+	slog.Info("守护模式启动", "schedule", cfg.Schedule, "timezone", cfg.Location.String(), "jitter", cfg.ScheduleJitter)
+	var wg sync.WaitGroup
+	if opts.addr != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.serveHTTP(ctx, opts); err != nil {
+				fatal("HTTP 服务异常退出", "err", err)
+			}
+		}()
+	}
+	a.scheduler.Run(ctx)
+	wg.Wait()
+	slog.Info("守护模式已退出")
+}
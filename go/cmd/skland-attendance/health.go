@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"skland-daily-attendance-go/internal/attendance"
+	"skland-daily-attendance-go/internal/server"
+	"skland-daily-attendance-go/internal/skland"
+	"skland-daily-attendance-go/internal/storage"
+	"skland-daily-attendance-go/internal/version"
+)
+
+// readyCheckTimeout bounds each readiness check.
+const readyCheckTimeout = 2 * time.Second
+
+// healthz reports that the process is alive.
+func (a *app) healthz(w http.ResponseWriter, r *http.Request) {
+	server.WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz reports whether runs can succeed: the configuration is usable, the
+// store is reachable and, when READY_CHECK_SKLAND is set, the Skland API host
+// resolves. It responds 503 when any check fails.
+func (a *app) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyCheckTimeout)
+	defer cancel()
+
+	checks := map[string]string{}
+	ready := true
+	check := func(name string, err error) {
+		if err != nil {
+			checks[name] = err.Error()
+			ready = false
+			return
+		}
+		checks[name] = "ok"
+	}
+
+	var cfgErr error
+	if len(a.cfg.Tokens) == 0 {
+		cfgErr = errors.New("no tokens configured")
+	}
+	check("config", cfgErr)
+	check("store", pingStore(a.store))
+	if a.cfg.ReadyCheckSkland {
+		_, err := net.DefaultResolver.LookupHost(ctx, skland.Host)
+		check("skland", err)
+	}
+
+	status, code := "ok", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	server.WriteJSON(w, code, map[string]any{"status": status, "checks": checks})
+}
+
+func pingStore(store storage.Store) error {
+	if p, ok := store.(storage.Pinger); ok {
+		return p.Ping()
+	}
+	_, _, err := store.Get(storage.RunIndexKey)
+	return err
+}
+
+// version returns the build information of the binary.
+func (a *app) version(w http.ResponseWriter, r *http.Request) {
+	server.WriteJSON(w, http.StatusOK, version.Get())
+}
+
+// statusResponse is the JSON body of /status.
+type statusResponse struct {
+	StartedAt time.Time             `json:"startedAt"`
+	LastRun   *attendance.RunRecord `json:"lastRun,omitempty"`
+	// Schedule and NextRun are set when the scheduler is active.
+	Schedule string     `json:"schedule,omitempty"`
+	NextRun  *time.Time `json:"nextRun,omitempty"`
+}
+
+// status reports the last run and the next scheduled run.
+func (a *app) status(w http.ResponseWriter, r *http.Request) {
+	resp := statusResponse{StartedAt: a.started}
+	runs, err := a.history.List(1)
+	if err != nil {
+		server.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(runs) > 0 {
+		resp.LastRun = &runs[0]
+	}
+	if a.scheduler != nil {
+		resp.Schedule = a.cfg.Schedule
+		if next := a.scheduler.Next(); !next.IsZero() {
+			resp.NextRun = &next
+		}
+	}
+	server.WriteJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"skland-daily-attendance-go/internal/attendance"
+	"skland-daily-attendance-go/internal/events"
+	"skland-daily-attendance-go/internal/metrics"
+	"skland-daily-attendance-go/internal/server"
+)
+
+const (
+	// httpRunTimeout bounds how long /attendance waits for a run.
+	httpRunTimeout = 2 * time.Minute
+	// maxRequestBody is the largest request body accepted.
+	maxRequestBody = 64 << 10
+	// shutdownTimeout lets an in-flight request finish on SIGTERM.
+	shutdownTimeout = httpRunTimeout + 30*time.Second
+	// defaultRunsLimit is the number of runs listed by GET /runs by default.
+	defaultRunsLimit = 20
+	// sseHeartbeat keeps idle event streams open through proxies.
+	sseHeartbeat = 15 * time.Second
+)
+
+// runResponse is the JSON body returned by the /attendance endpoint.
+type runResponse struct {
+	attendance.Result
+	NotifyError string `json:"notifyError,omitempty"`
+}
+
+// httpOptions are the command line flags of HTTP mode.
+type httpOptions struct {
+	addr    string
+	tlsCert string
+	tlsKey  string
+}
+
+// runHTTP serves the HTTP endpoints until SIGINT or SIGTERM. Runs started
+// through /runs that are still in flight are finished by the coordinator
+// independently of the server.
+func (a *app) runHTTP(opts httpOptions) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := a.serveHTTP(ctx, opts); err != nil {
+		fatal("HTTP 服务异常退出", "err", err)
+	}
+	slog.Info("HTTP 服务已关闭")
+}
+
+// serveHTTP serves the HTTP endpoints until ctx is cancelled.
+func (a *app) serveHTTP(ctx context.Context, opts httpOptions) error {
+	if (opts.tlsCert == "") != (opts.tlsKey == "") {
+		return errors.New("-tls-cert 与 -tls-key 需同时设置")
+	}
+	srv := server.New(opts.addr, a.handler())
+	slog.Info("HTTP 服务启动", "addr", opts.addr, "tls", opts.tlsCert != "")
+	return server.Serve(ctx, srv, opts.tlsCert, opts.tlsKey, shutdownTimeout)
+}
+
+// handler routes the HTTP endpoints.
+func (a *app) handler() http.Handler {
+	cfg := a.cfg
+	auth := server.NewAuthenticator(cfg.HTTPAuthTokens, cfg.HTTPHMACSecret)
+	if !auth.Enabled() {
+		slog.Warn("未设置 HTTP_AUTH_TOKENS 或 HTTP_HMAC_SECRET，任何人都可以触发签到，请勿暴露到公网")
+	}
+	limiter := server.NewRateLimiter(cfg.HTTPRateLimit, cfg.HTTPRateLimit)
+
+	// protect applies, outermost first: rate limit (triggers only), body
+	// size, authentication. Failed authentications are limited on every
+	// protected route by the authenticator itself.
+	protect := func(h http.HandlerFunc, trigger bool) http.Handler {
+		var handler http.Handler = auth.Wrap(h)
+		handler = server.LimitBody(handler, maxRequestBody)
+		if trigger {
+			handler = limiter.Wrap(handler)
+		}
+		return handler
+	}
+	get := func(h http.HandlerFunc) http.Handler {
+		return server.Methods{http.MethodGet: h, http.MethodHead: h}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/attendance", server.Methods{
+		http.MethodPost: protect(a.attendance, true),
+	})
+	mux.Handle("/runs", server.Methods{
+		http.MethodPost: protect(a.startRun, true),
+		http.MethodGet:  protect(a.listRuns, false),
+	})
+	mux.Handle("/runs/", server.Methods{
+		http.MethodGet: protect(a.runResource, false),
+	})
+	// Probes and build information are public; /status and /metrics
+	// reveal run outcomes and require authentication.
+	mux.Handle("/healthz", get(a.healthz))
+	mux.Handle("/readyz", get(a.readyz))
+	mux.Handle("/version", get(a.version))
+	mux.Handle("/status", server.Methods{http.MethodGet: protect(a.status, false)})
+	mux.Handle("/metrics", server.Methods{http.MethodGet: protect(metrics.Default.Handler().ServeHTTP, false)})
+	return mux
+}
+
+// attendance runs attendance synchronously and returns its result.
+func (a *app) attendance(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), httpRunTimeout)
+	defer cancel()
+
+	// Concurrent requests join the run in flight and share its result.
+	out := a.coord.Run(ctx)
+	result, err, pushErr := out.Result, out.Err, out.NotifyErr
+
+	if err == nil && pushErr != nil && a.cfg.NotificationStrict {
+		err = pushErr
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, attendance.ErrRunInProgress) {
+			status = http.StatusConflict
+		}
+		server.WriteJSON(w, status, map[string]any{
+			"result": "failed",
+			"error":  err.Error(),
+		})
+		return
+	}
+	resp := runResponse{Result: result}
+	if pushErr != nil {
+		resp.NotifyError = pushErr.Error()
+	}
+	server.WriteJSON(w, http.StatusOK, resp)
+}
+
+// startRun starts a run, or joins the one in flight, and returns its ID
+// without waiting for it. A run with other options in flight is a conflict.
+func (a *app) startRun(w http.ResponseWriter, r *http.Request) {
+	id, err := a.coord.Start()
+	if err != nil {
+		server.WriteJSON(w, http.StatusConflict, map[string]any{
+			"result": "failed",
+			"error":  err.Error(),
+		})
+		return
+	}
+	w.Header().Set("Location", "/runs/"+id)
+	server.WriteJSON(w, http.StatusAccepted, map[string]string{
+		"id":     id,
+		"status": attendance.StatusRunning,
+	})
+}
+
+// listRuns returns the most recent runs, newest first; ?limit=N caps the list.
+func (a *app) listRuns(w http.ResponseWriter, r *http.Request) {
+	limit := defaultRunsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			server.WriteError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	runs, err := a.history.List(limit)
+	if err != nil {
+		server.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	server.WriteJSON(w, http.StatusOK, map[string]any{"runs": runs})
+}
+
+// runResource routes /runs/{id} and /runs/{id}/events.
+func (a *app) runResource(w http.ResponseWriter, r *http.Request) {
+	id, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/runs/"), "/")
+	switch {
+	case id == "":
+	case sub == "":
+		a.getRun(w, r, id)
+		return
+	case sub == "events":
+		a.streamEvents(w, r, id)
+		return
+	}
+	server.WriteError(w, http.StatusNotFound, "not found")
+}
+
+// getRun returns the status, progress and result of a run.
+func (a *app) getRun(w http.ResponseWriter, r *http.Request, id string) {
+	rec, ok, err := a.history.Get(id)
+	if err != nil {
+		server.WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		server.WriteError(w, http.StatusNotFound, "run not found")
+		return
+	}
+	server.WriteJSON(w, http.StatusOK, rec)
+}
+
+// streamEvents streams the events of a run as Server-Sent Events: first the
+// events already emitted (after Last-Event-ID, when reconnecting), then live
+// events until the run ends.
+func (a *app) streamEvents(w http.ResponseWriter, r *http.Request, id string) {
+	replay, live, cancel, ok := a.events.Subscribe(id)
+	if !ok {
+		server.WriteError(w, http.StatusNotFound, "no events for run")
+		return
+	}
+	defer cancel()
+
+	after, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+	rc := http.NewResponseController(w)
+	// Streams outlive the server write timeout meant for plain requests.
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(e events.Event) error {
+		if e.Seq <= after {
+			return nil
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, b); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	for _, e := range replay {
+		if write(e) != nil {
+			return
+		}
+	}
+	_ = rc.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-live:
+			if !ok {
+				return
+			}
+			if write(e) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"skland-daily-attendance-go/internal/attendance"
+	"skland-daily-attendance-go/internal/config"
+	"skland-daily-attendance-go/internal/skland/sklandtest"
+)
+
+// webhookRecorder is a webhook target recording every delivered report.
+type webhookRecorder struct {
+	*httptest.Server
+	mu      sync.Mutex
+	reports []webhookReport
+}
+
+type webhookReport struct {
+	Messages []struct {
+		Text    string    `json:"text"`
+		Account int       `json:"account"`
+		Time    time.Time `json:"time"`
+	} `json:"messages"`
+	Summary *struct {
+		Accounts struct {
+			Total int `json:"total"`
+		} `json:"accounts"`
+	} `json:"summary"`
+}
+
+func newWebhookRecorder(t *testing.T) *webhookRecorder {
+	t.Helper()
+	rec := &webhookRecorder{}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report webhookReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Errorf("decode webhook payload: %v", err)
+		}
+		rec.mu.Lock()
+		rec.reports = append(rec.reports, report)
+		rec.mu.Unlock()
+	}))
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+func newTestApp(t *testing.T, sk *sklandtest.Server, env map[string]string) *app {
+	t.Helper()
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	a, err := newApp(cfg, sk.Option())
+	if err != nil {
+		t.Fatalf("newApp: %v", err)
+	}
+	return a
+}
+
+// TestConcurrentAttendance fires waves of parallel /attendance requests and
+// checks that every run is pushed exactly once to each webhook, with only
+// its own messages. Run it with -race.
+func TestConcurrentAttendance(t *testing.T) {
+	sk := sklandtest.New(t)
+	sk.AddAccount("tok-a", sklandtest.Arknights("a1", "阿米娅"))
+	sk.AddAccount("tok-b", sklandtest.Arknights("b1", "凯尔希"), sklandtest.Endfield("b2", "r2", "管理员"))
+	// Slow responses keep runs in flight while the next requests arrive.
+	sk.SetDelay(5 * time.Millisecond)
+
+	hookA, hookB := newWebhookRecorder(t), newWebhookRecorder(t)
+	a := newTestApp(t, sk, map[string]string{
+		"TOKENS":            "tok-a,tok-b",
+		"NOTIFICATION_URLS": hookA.URL + "," + hookB.URL,
+		"HTTP_RATE_LIMIT":   "0",
+		"STORE_PATH":        "",
+	})
+	srv := httptest.NewServer(a.handler())
+	defer srv.Close()
+
+	const waves, parallel = 3, 8
+	for wave := 0; wave < waves; wave++ {
+		var wg sync.WaitGroup
+		for i := 0; i < parallel; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := srv.Client().Post(srv.URL+"/attendance", "application/json", nil)
+				if err != nil {
+					t.Errorf("POST /attendance: %v", err)
+					return
+				}
+				defer resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					t.Errorf("POST /attendance: status %d", resp.StatusCode)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	runs, err := a.history.List(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) < waves {
+		t.Fatalf("got %d runs, want at least one per wave (%d)", len(runs), waves)
+	}
+	if got := sk.Claims(); len(got) != 3 {
+		t.Errorf("claims = %v, want each character claimed once", got)
+	}
+
+	for name, hook := range map[string]*webhookRecorder{"A": hookA, "B": hookB} {
+		hook.mu.Lock()
+		reports := hook.reports
+		hook.mu.Unlock()
+		if len(reports) != len(runs) {
+			t.Errorf("webhook %s got %d reports, want one per run (%d)", name, len(reports), len(runs))
+		}
+
+		// Each report must consist of the messages of exactly one run,
+		// and each run must be reported once.
+		seen := make(map[string]int)
+		for i, report := range reports {
+			if report.Summary == nil || report.Summary.Accounts.Total != 2 {
+				t.Errorf("webhook %s report %d: summary = %+v, want 2 accounts", name, i, report.Summary)
+			}
+			if len(report.Messages) == 0 {
+				t.Errorf("webhook %s report %d has no messages", name, i)
+				continue
+			}
+			var owner string
+			for _, m := range report.Messages {
+				id := runAt(runs, m.Time)
+				if id == "" {
+					t.Errorf("webhook %s report %d: message %q at %s belongs to no run", name, i, m.Text, m.Time)
+					continue
+				}
+				if owner == "" {
+					owner = id
+				} else if id != owner {
+					t.Errorf("webhook %s report %d mixes runs %s and %s", name, i, owner, id)
+				}
+			}
+			seen[owner]++
+		}
+		for _, run := range runs {
+			if seen[run.ID] != 1 {
+				t.Errorf("webhook %s got run %s %d times, want once", name, run.ID, seen[run.ID])
+			}
+		}
+	}
+}
+
+// runAt returns the ID of the run in progress at t.
+func runAt(runs []attendance.RunRecord, t time.Time) string {
+	for _, run := range runs {
+		if run.FinishedAt != nil && !t.Before(run.StartedAt) && !t.After(*run.FinishedAt) {
+			return run.ID
+		}
+	}
+	return ""
+}
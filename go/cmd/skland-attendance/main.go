@@ -2,74 +2,198 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
-	"log"
-	"net/http"
+	"fmt"
+	"log/slog"
 	"os"
 	"time"
 
 	"skland-daily-attendance-go/internal/attendance"
 	"skland-daily-attendance-go/internal/config"
+	"skland-daily-attendance-go/internal/events"
+	"skland-daily-attendance-go/internal/ghactions"
+	"skland-daily-attendance-go/internal/logging"
 	"skland-daily-attendance-go/internal/notify"
+	"skland-daily-attendance-go/internal/scheduler"
+	"skland-daily-attendance-go/internal/skland"
 	"skland-daily-attendance-go/internal/storage"
+	"skland-daily-attendance-go/internal/tracing"
 )
 
+const (
+	// runTimeout bounds a single run, including notification delivery.
+	runTimeout = 10 * time.Minute
+	// historyLimit is the number of run records retained in the store.
+	historyLimit = 50
+	// eventStreamLimit is the number of finished runs whose events are kept
+	// for replay.
+	eventStreamLimit = 10
+	// traceFlushTimeout bounds the export of pending spans on exit.
+	traceFlushTimeout = 5 * time.Second
+)
+
+// app holds the components shared by every mode.
+type app struct {
+	cfg      *config.Config
+	store    storage.Store
+	notifier *notify.WebhookNotifier
+	svc      *attendance.Service
+	coord    *attendance.Coordinator
+	history  *attendance.History
+	events   *events.Bus
+	// scheduler is set in daemon mode.
+	scheduler *scheduler.Scheduler
+	// tracer is set when tracing is enabled.
+	tracer  *tracing.Provider
+	started time.Time
+}
+
 func main() {
-	mode := flag.String("mode", "once", "运行模式: once | http")
-	addr := flag.String("addr", ":8080", "HTTP 监听地址 (mode=http 时生效)")
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
+
+	// Without a subcommand the legacy -mode flag selects what to do.
+	flag.Usage = usage
+	mode := flag.String("mode", "once", "运行模式: once | http | daemon")
+	addr := flag.String("addr", ":8080", "HTTP 监听地址 (mode=http，或 mode=daemon 且设置 -serve 时生效)")
+	serve := flag.Bool("serve", false, "mode=daemon 时同时提供 HTTP 接口")
+	tlsCert := flag.String("tls-cert", "", "TLS 证书文件 (提供 HTTP 接口时生效，需同时设置 -tls-key)")
+	tlsKey := flag.String("tls-key", "", "TLS 私钥文件 (提供 HTTP 接口时生效)")
 	flag.Parse()
 
-	cfg, err := config.Load()
+	cfg, err := loadConfig()
 	if err != nil {
-		log.Fatalf("加载配置失败: %v", err)
+		fatal("启动失败", "err", err)
 	}
-
-	store := storage.NewMemoryStore()
-	notifier := notify.NewWebhookNotifier(cfg.NotificationURLs)
-	svc := attendance.NewService(cfg, store, notifier)
+	a, err := newApp(cfg)
+	if err != nil {
+		fatal("启动失败", "err", err)
+	}
+	httpOpts := httpOptions{addr: *addr, tlsCert: *tlsCert, tlsKey: *tlsKey}
 
 	switch *mode {
 	case "once":
-		ctx := context.Background()
-		result, err := svc.Run(ctx)
-		if err != nil {
-			log.Printf("执行失败: %v", err)
-		}
-		_ = notifier.Push(ctx)
-		if result.Result == "failed" || err != nil {
-			os.Exit(1)
-		}
+		// The coordinator logs the outcome.
+		out := a.coord.Run(context.Background())
+		reportActions(out)
+		code := a.exitCode(out)
+		a.shutdown()
+		os.Exit(code)
 	case "http":
-		mux := http.NewServeMux()
-		mux.HandleFunc("/attendance", func(w http.ResponseWriter, r *http.Request) {
-			ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
-			defer cancel()
+		a.runHTTP(httpOpts)
+	case "daemon":
+		if !*serve {
+			httpOpts.addr = ""
+		}
+		a.runDaemon(httpOpts)
+	default:
+		fatal("未知模式", "mode", *mode)
+	}
+	a.shutdown()
+}
 
-			result, err := svc.Run(ctx)
-			_ = notifier.Push(ctx)
+// loadConfig loads the configuration and installs the logger it describes.
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("加载配置失败: %w", err)
+	}
+	// Mask credentials before anything can print them.
+	if gh := ghactions.Detect(); gh != nil {
+		gh.Mask(cfg.Secrets()...)
+	}
+	logger, err := newLogger(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("加载日志配置失败: %w", err)
+	}
+	slog.SetDefault(logger)
+	return cfg, nil
+}
 
-			w.Header().Set("Content-Type", "application/json")
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				_ = json.NewEncoder(w).Encode(map[string]any{
-					"result": "failed",
-					"error":  err.Error(),
-				})
-				return
-			}
-			if result.Result == "failed" {
-				w.WriteHeader(http.StatusOK)
-			}
-			_ = json.NewEncoder(w).Encode(result)
-		})
+// newApp wires the components for cfg, creating Skland clients with
+// clientOpts.
+func newApp(cfg *config.Config, clientOpts ...skland.Option) (*app, error) {
+	store, err := storage.Open(cfg.StorePath)
+	if err != nil {
+		return nil, fmt.Errorf("打开存储失败: %w", err)
+	}
+	notifier, err := notify.New(cfg, store)
+	if err != nil {
+		return nil, fmt.Errorf("加载通知配置失败: %w", err)
+	}
+	svc := attendance.NewService(cfg, store, clientOpts...)
 
-		log.Printf("HTTP 模式启动，监听 %s", *addr)
-		if err := http.ListenAndServe(*addr, mux); err != nil {
-			log.Fatalf("HTTP 服务启动失败: %v", err)
+	a := &app{
+		cfg:      cfg,
+		store:    store,
+		notifier: notifier,
+		svc:      svc,
+		history:  attendance.NewHistory(store, historyLimit),
+		events:   events.NewBus(eventStreamLimit),
+		tracer: tracing.Setup(tracing.Options{
+			Endpoint:    cfg.TracingEndpoint,
+			Headers:     cfg.TracingHeaders,
+			ServiceName: cfg.ServiceName,
+		}),
+		started: time.Now(),
+	}
+	coordOpts := attendance.CoordinatorOptions{Timeout: runTimeout, History: a.history, Events: a.events}
+	if cfg.RunLock {
+		locker, ok := store.(storage.Locker)
+		if !ok {
+			return nil, errors.New("当前存储不支持 RUN_LOCK")
 		}
-	default:
-		log.Fatalf("未知模式: %s", *mode)
+		coordOpts.Lock = locker
 	}
+	a.coord = attendance.NewCoordinator(svc, notifier, coordOpts)
+	return a, nil
 }
 
+// exitCode maps the outcome of a run to the process exit status: 1 when
+// the run failed, or when notifications failed and NOTIFICATION_STRICT is
+// set. A run skipped because another one holds the lock is not a failure.
+func (a *app) exitCode(out attendance.Outcome) int {
+	if errors.Is(out.Err, attendance.ErrRunInProgress) {
+		return 0
+	}
+	if out.Result.Result == "failed" || out.Err != nil || (out.NotifyErr != nil && a.cfg.NotificationStrict) {
+		return 1
+	}
+	return 0
+}
+
+// shutdown exports the spans still pending.
+func (a *app) shutdown() {
+	if a.tracer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+	defer cancel()
+	if err := a.tracer.Shutdown(ctx); err != nil {
+		slog.Warn("导出链路追踪数据失败", "err", err)
+	}
+}
+
+// newLogger creates the process logger, redacting every credential found
+// in the configuration.
+func newLogger(cfg *config.Config) (*slog.Logger, error) {
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	return logging.New(os.Stderr, logging.Options{
+		Format:  cfg.LogFormat,
+		Level:   level,
+		Secrets: cfg.Secrets(),
+	})
+}
+
+// fatal logs an error and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -3,79 +3,158 @@ package attendance
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"skland-daily-attendance-go/internal/logging"
 	"skland-daily-attendance-go/internal/skland"
 )
 
 // AttendanceResult mirrors the TypeScript version.
 type AttendanceResult struct {
-	Success bool
-	Message string
-	HasError bool
+	Success   bool
+	Message   string
+	HasError  bool
+	Character string
+	Rewards   []string
+	// WouldSign is set by dry runs for characters that have not attended
+	// today.
+	WouldSign bool
 }
 
-// isTodayAttended checks whether the attendance status already contains today's record.
-func isTodayAttendedArknights(status *skland.ArknightsAttendanceStatus) bool {
-	today := time.Now().Truncate(24 * time.Hour)
+// CharacterOptions configures the attendance of a character.
+type CharacterOptions struct {
+	// SessionToken is the token of the signed-in account.
+	SessionToken string
+	// MaxRetries is the number of attempts; values below 1 mean 1.
+	MaxRetries int
+	// Location decides which day is today; nil means UTC.
+	Location *time.Location
+	// DryRun checks the attendance status without claiming the reward.
+	DryRun bool
+}
+
+// isTodayAttendedArknights checks whether the attendance status already
+// contains a record of today in loc.
+func isTodayAttendedArknights(status *skland.ArknightsAttendanceStatus, loc *time.Location) bool {
+	if loc == nil {
+		loc = time.UTC
+	}
+	y, m, d := time.Now().In(loc).Date()
 	for _, r := range status.Records {
-		ts := time.Unix(r.TS, 0).Truncate(24 * time.Hour)
-		if ts.Equal(today) {
+		ry, rm, rd := time.Unix(int64(r.TS), 0).In(loc).Date()
+		if ry == y && rm == m && rd == d {
 			return true
 		}
 	}
 	return false
 }
 
-// AttendCharacter performs attendance for a single character.
-func AttendCharacter(ctx context.Context, client *skland.Client, character skland.AppBindingPlayer, maxRetries int, appName string) AttendanceResult {
+// AttendCharacter performs attendance for a single character, or only
+// checks whether it would be performed when opts.DryRun is set.
+func AttendCharacter(ctx context.Context, client *skland.Client, character skland.AppBindingPlayer, appName string, opts CharacterOptions) AttendanceResult {
 	var lastErr error
+	maxRetries := opts.MaxRetries
 	if maxRetries <= 0 {
 		maxRetries = 1
 	}
 
 	for attempt := 0; attempt < maxRetries; attempt++ {
-		res, err := attendOnce(ctx, client, character, appName)
+		res, err := attendOnce(ctx, client, character, appName, opts)
 		if err == nil {
 			return res
 		}
 		lastErr = err
+		logging.FromContext(ctx).Warn("角色签到失败",
+			"game", character.GameName,
+			"character", formatCharacterName(character, appName),
+			"attempt", attempt+1,
+			"max_attempts", maxRetries,
+			"err", err)
+		if ctx.Err() != nil {
+			break
+		}
 	}
 
 	return AttendanceResult{
-		Success: false,
-		Message: fmt.Sprintf("%s 签到过程中出现未知错误: %v", character.GameName, lastErr),
-		HasError: true,
+		Success:   false,
+		Message:   fmt.Sprintf("%s 签到过程中出现未知错误: %v", character.GameName, lastErr),
+		HasError:  true,
+		Character: formatCharacterName(character, appName),
 	}
 }
 
-func attendOnce(ctx context.Context, client *skland.Client, character skland.AppBindingPlayer, appName string) (AttendanceResult, error) {
+func attendOnce(ctx context.Context, client *skland.Client, character skland.AppBindingPlayer, appName string, opts CharacterOptions) (AttendanceResult, error) {
 	label := formatCharacterName(character, appName)
 
 	// gameId 3: Endfield
 	if character.GameID == 3 {
 		if character.DefaultRole == nil {
 			return AttendanceResult{
-				Success: false,
-				Message: fmt.Sprintf("%s 没有角色，跳过签到", label),
-				HasError: false,
+				Success:   false,
+				Message:   fmt.Sprintf("%s 没有角色，跳过签到", label),
+				HasError:  false,
+				Character: label,
 			}, nil
 		}
-		// The detailed Endfield attendance is omitted here; in a full implementation,
-		// you would call specific game APIs similar to the TypeScript version.
-		return AttendanceResult{
-			Success: true,
-			Message: fmt.Sprintf("%s 签到成功（终末地占位实现）", label),
-			HasError: false,
-		}, nil
+		status, err := client.GetEndfieldAttendanceStatus(ctx, opts.SessionToken, character)
+		if err != nil {
+			return AttendanceResult{}, err
+		}
+		if status.HasToday {
+			return alreadyAttended(label), nil
+		}
+		if opts.DryRun {
+			return wouldSign(label), nil
+		}
+		res, err := client.AttendEndfield(ctx, opts.SessionToken, character)
+		if err != nil {
+			return AttendanceResult{}, err
+		}
+		var rewards []string
+		for _, a := range res.AwardIDs {
+			if info, ok := res.ResourceInfoMap[a.ID]; ok {
+				rewards = append(rewards, info.Name)
+			}
+		}
+		return signed(label, rewards), nil
 	}
 
-	// For other games, we use a generic attendance call.
-	return AttendanceResult{
-		Success: true,
-		Message: fmt.Sprintf("%s 签到成功（占位实现）", label),
-		HasError: false,
-	}, nil
+	status, err := client.GetAttendanceStatus(ctx, opts.SessionToken, character)
+	if err != nil {
+		return AttendanceResult{}, err
+	}
+	if isTodayAttendedArknights(status, opts.Location) {
+		return alreadyAttended(label), nil
+	}
+	if opts.DryRun {
+		return wouldSign(label), nil
+	}
+	res, err := client.Attend(ctx, opts.SessionToken, character)
+	if err != nil {
+		return AttendanceResult{}, err
+	}
+	var rewards []string
+	for _, a := range res.Awards {
+		rewards = append(rewards, fmt.Sprintf("%sx%d", a.Resource.Name, a.Count))
+	}
+	return signed(label, rewards), nil
+}
+
+func signed(label string, rewards []string) AttendanceResult {
+	msg := fmt.Sprintf("%s 签到成功", label)
+	if len(rewards) > 0 {
+		msg += "，获得 " + strings.Join(rewards, "、")
+	}
+	return AttendanceResult{Success: true, Message: msg, Character: label, Rewards: rewards}
+}
+
+func alreadyAttended(label string) AttendanceResult {
+	return AttendanceResult{Message: fmt.Sprintf("%s 今天已经签到过", label), Character: label}
+}
+
+func wouldSign(label string) AttendanceResult {
+	return AttendanceResult{Message: fmt.Sprintf("%s 尚未签到，将会签到（演练模式）", label), Character: label, WouldSign: true}
 }
 
 // formatCharacterName approximates utils/format.ts behaviour.
@@ -88,4 +167,3 @@ func formatCharacterName(character skland.AppBindingPlayer, appName string) stri
 	}
 	return appName
 }
-
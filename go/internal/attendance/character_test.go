@@ -0,0 +1,140 @@
+package attendance
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"skland-daily-attendance-go/internal/skland"
+	"skland-daily-attendance-go/internal/skland/sklandtest"
+	"skland-daily-attendance-go/internal/storage"
+)
+
+func TestAttendCharacter(t *testing.T) {
+	const (
+		statusGet    = "GET /api/v1/game/attendance"
+		claimPost    = "POST /api/v1/game/attendance"
+		endfieldGet  = "GET /web/v1/game/endfield/attendance"
+		endfieldPost = "POST /web/v1/game/endfield/attendance"
+	)
+	tests := []struct {
+		name      string
+		character skland.AppBindingPlayer
+		attended  bool
+		dryRun    bool
+		want      AttendanceResult
+		requests  map[string]int
+	}{
+		{
+			name:      "arknights signs",
+			character: sklandtest.Arknights("a1", "阿米娅"),
+			want:      AttendanceResult{Success: true, Message: "明日方舟-阿米娅 签到成功，获得 龙门币x500", Rewards: []string{"龙门币x500"}},
+			requests:  map[string]int{statusGet: 1, claimPost: 1},
+		},
+		{
+			name:      "arknights already attended",
+			character: sklandtest.Arknights("a1", "阿米娅"),
+			attended:  true,
+			want:      AttendanceResult{Message: "明日方舟-阿米娅 今天已经签到过"},
+			requests:  map[string]int{statusGet: 1, claimPost: 0},
+		},
+		{
+			name:      "arknights dry run",
+			character: sklandtest.Arknights("a1", "阿米娅"),
+			dryRun:    true,
+			want:      AttendanceResult{Message: "明日方舟-阿米娅 尚未签到，将会签到（演练模式）", WouldSign: true},
+			requests:  map[string]int{statusGet: 1, claimPost: 0},
+		},
+		{
+			name:      "endfield signs",
+			character: sklandtest.Endfield("e1", "r1", "管理员"),
+			want:      AttendanceResult{Success: true, Message: "明日方舟：终末地-管理员 签到成功，获得 折金票", Rewards: []string{"折金票"}},
+			requests:  map[string]int{endfieldGet: 1, endfieldPost: 1},
+		},
+		{
+			name:      "endfield dry run already attended",
+			character: sklandtest.Endfield("e1", "r1", "管理员"),
+			attended:  true,
+			dryRun:    true,
+			want:      AttendanceResult{Message: "明日方舟：终末地-管理员 今天已经签到过"},
+			requests:  map[string]int{endfieldGet: 1, endfieldPost: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sk := sklandtest.New(t)
+			sk.AddAccount("tok", tt.character)
+			if tt.attended {
+				sk.SetAttended(tt.character.UID)
+			}
+			client := sk.Client()
+			ctx := context.Background()
+			code, err := client.GrantAuthorizeCode(ctx, "tok")
+			if err != nil {
+				t.Fatal(err)
+			}
+			session, err := client.SignIn(ctx, code)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := AttendCharacter(ctx, client, tt.character, tt.character.GameName, CharacterOptions{
+				SessionToken: session,
+				MaxRetries:   1,
+				DryRun:       tt.dryRun,
+			})
+			if got.Success != tt.want.Success || got.WouldSign != tt.want.WouldSign || got.HasError ||
+				got.Message != tt.want.Message || strings.Join(got.Rewards, ",") != strings.Join(tt.want.Rewards, ",") {
+				t.Errorf("AttendCharacter = %+v, want %+v", got, tt.want)
+			}
+			for endpoint, n := range tt.requests {
+				if got := sk.Requests(endpoint); got != n {
+					t.Errorf("%s requested %d times, want %d (%s)", endpoint, got, n, sk)
+				}
+			}
+		})
+	}
+}
+
+func TestAttendCharacterRetries(t *testing.T) {
+	sk := sklandtest.New(t)
+	character := sklandtest.Arknights("a1", "阿米娅")
+	sk.AddAccount("tok", character)
+	sk.SetAttended("a1")
+
+	// An unknown session fails every attempt.
+	got := AttendCharacter(context.Background(), sk.Client(), character, character.GameName, CharacterOptions{
+		SessionToken: "session:unknown",
+		MaxRetries:   3,
+	})
+	if !got.HasError || !strings.Contains(got.Message, "签到过程中出现未知错误") {
+		t.Errorf("AttendCharacter = %+v, want an error", got)
+	}
+	if n := sk.Requests("GET /api/v1/game/attendance"); n != 3 {
+		t.Errorf("status requested %d times, want 3 attempts", n)
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	sk := sklandtest.New(t)
+	sk.AddAccount("tok-a", sklandtest.Arknights("a1", "阿米娅"), sklandtest.Endfield("a2", "r2", "管理员"))
+	sk.SetAttended("a2")
+
+	store := storage.NewMemoryStore()
+	svc := NewService(testConfig(t, "tok-a"), store, sk.Option())
+	res, err := svc.RunWith(context.Background(), nil, RunOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("RunWith: %v", err)
+	}
+	if claims := sk.Claims(); len(claims) != 0 {
+		t.Errorf("dry run claimed %v", claims)
+	}
+	ark, end := res.Stats.CharactersByGame[1], res.Stats.CharactersByGame[3]
+	if ark == nil || ark.WouldSign != 1 || end == nil || end.AlreadyAttended != 1 {
+		t.Errorf("stats = %+v / %+v, want one character to sign and one attended", ark, end)
+	}
+	key, _ := storage.GenerateAttendanceKey("tok-a")
+	if ok, _ := store.HasAttended(key); ok {
+		t.Error("dry run recorded the account as attended")
+	}
+}
@@ -0,0 +1,273 @@
+package attendance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"skland-daily-attendance-go/internal/events"
+	"skland-daily-attendance-go/internal/logging"
+	"skland-daily-attendance-go/internal/metrics"
+	"skland-daily-attendance-go/internal/notify"
+	"skland-daily-attendance-go/internal/storage"
+)
+
+// ErrRunInProgress is returned when another process holds the run lock.
+var ErrRunInProgress = errors.New("another run is in progress")
+
+// runLockName is the name of the distributed lock guarding runs.
+const runLockName = "run"
+
+// Outcome is the result of a coordinated run, including its notification
+// delivery.
+type Outcome struct {
+	RunID  string
+	Result Result
+	// Err is the error of the run itself.
+	Err error
+	// NotifyErr is the error of pushing the run's notifications.
+	NotifyErr error
+}
+
+// Report returns the detailed report of the run. A run that could not
+// start, for instance because the lock is held, gets a report carrying
+// only the error.
+func (o Outcome) Report() *RunReport {
+	r := o.Result.Report
+	if r == nil {
+		now := time.Now()
+		r = &RunReport{Result: StatusError, StartedAt: now, FinishedAt: now, Accounts: []AccountReport{}}
+	}
+	r.RunID = o.RunID
+	if o.Err != nil {
+		r.Result = StatusError
+		r.Error = o.Err.Error()
+	}
+	return r
+}
+
+// CoordinatorOptions configures a Coordinator.
+type CoordinatorOptions struct {
+	// Timeout bounds each run, including notification delivery.
+	Timeout time.Duration
+	// Lock, when set, is acquired around each run so that processes sharing
+	// the backend never run at the same time.
+	Lock storage.Locker
+	// History, when set, records the status, progress and result of runs.
+	History *History
+	// Events, when set, receives the progress events of runs.
+	Events *events.Bus
+}
+
+// Coordinator serialises runs: concurrent triggers are coalesced into a
+// single in-flight run whose outcome is returned to every caller.
+type Coordinator struct {
+	svc      *Service
+	notifier notify.Notifier
+	timeout  time.Duration
+	lock     storage.Locker
+	history  *History
+	events   *events.Bus
+	owner    string
+
+	mu       sync.Mutex
+	inflight *call
+}
+
+type call struct {
+	id      string
+	opts    RunOptions
+	done    chan struct{}
+	outcome Outcome
+}
+
+// NewCoordinator creates a coordinator running svc and pushing through notifier.
+func NewCoordinator(svc *Service, notifier notify.Notifier, opts CoordinatorOptions) *Coordinator {
+	host, _ := os.Hostname()
+	return &Coordinator{
+		svc:      svc,
+		notifier: notifier,
+		timeout:  opts.Timeout,
+		lock:     opts.Lock,
+		history:  opts.History,
+		events:   opts.Events,
+		owner:    fmt.Sprintf("%s:%d", host, os.Getpid()),
+	}
+}
+
+// Run starts a run, or joins the one in flight, and waits for its outcome.
+// The run is not tied to ctx: if ctx ends first Run returns ctx.Err() while
+// the run completes in the background.
+func (c *Coordinator) Run(ctx context.Context) Outcome {
+	return c.RunWith(ctx, RunOptions{})
+}
+
+// RunWith is like Run for the accounts and games selected by opts. It only
+// joins an in-flight run started with the same options, and otherwise
+// fails with ErrRunInProgress.
+func (c *Coordinator) RunWith(ctx context.Context, opts RunOptions) Outcome {
+	cl, ok := c.start(opts)
+	if !ok {
+		return Outcome{Err: ErrRunInProgress}
+	}
+	select {
+	case <-cl.done:
+		return cl.outcome
+	case <-ctx.Done():
+		return Outcome{Err: ctx.Err()}
+	}
+}
+
+// Start starts a run, or joins the one in flight, and returns its ID without
+// waiting for it. Like RunWith, it fails with ErrRunInProgress while a run
+// with other options, such as a selective one, is in flight.
+func (c *Coordinator) Start() (string, error) {
+	cl, ok := c.start(RunOptions{})
+	if !ok {
+		return "", ErrRunInProgress
+	}
+	return cl.id, nil
+}
+
+// start returns the in-flight call, starting a new run with opts if there
+// is none. It reports false, with the in-flight call, when that call was
+// started with other options.
+func (c *Coordinator) start(opts RunOptions) (*call, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inflight != nil {
+		return c.inflight, reflect.DeepEqual(c.inflight.opts, opts)
+	}
+	cl := &call{id: NewRunID(), opts: opts, done: make(chan struct{})}
+	c.inflight = cl
+	rec := c.begin(cl.id)
+	var publish events.Publisher
+	if c.events != nil {
+		publish = c.events.Publisher(cl.id)
+	}
+	go func() {
+		startedAt := time.Now()
+		cl.outcome = c.execute(cl.id, opts, rec, publish)
+		c.finish(rec, cl.outcome, startedAt)
+		if c.events != nil {
+			c.events.Close(cl.id)
+		}
+		c.mu.Lock()
+		c.inflight = nil
+		c.mu.Unlock()
+		close(cl.done)
+	}()
+	return cl, true
+}
+
+// execute performs the run with the given ID and options, reporting
+// progress to rec and publish.
+func (c *Coordinator) execute(id string, opts RunOptions, rec *RunRecord, publish events.Publisher) Outcome {
+	ctx := context.Background()
+	if publish != nil {
+		ctx = events.WithPublisher(ctx, publish)
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	ctx = logging.With(ctx, "run_id", id)
+	log := logging.FromContext(ctx)
+	log.Info("开始执行签到", "accounts", len(c.svc.cfg.Tokens))
+
+	out := Outcome{RunID: id}
+	if c.lock != nil {
+		key := storage.GenerateLockKey(runLockName)
+		owner := c.owner + ":" + out.RunID
+		// Without a timeout the lock still expires, in case the process dies.
+		ttl := c.timeout
+		if ttl <= 0 {
+			ttl = time.Hour
+		}
+		ok, err := c.lock.TryLock(key, owner, ttl)
+		if err != nil {
+			out.Err = fmt.Errorf("acquire run lock: %w", err)
+		} else if !ok {
+			out.Err = ErrRunInProgress
+		}
+		if out.Err != nil {
+			log.Warn("未能获取执行锁", "err", out.Err)
+			events.Publish(ctx, events.Event{Type: events.RunFinished, Result: StatusError, Text: out.Err.Error()})
+			return out
+		}
+		defer func() { _ = c.lock.Unlock(key, owner) }()
+	}
+
+	var sess notify.Session = c.notifier.Begin(out.RunID)
+	if rec != nil {
+		sess = &progressSession{Session: sess, onAccount: func(account int) {
+			rec.Progress.Current = account
+			_ = c.history.update(rec)
+		}}
+	}
+	out.Result, out.Err = c.svc.RunWith(ctx, sess, opts)
+	if out.Result.Report != nil {
+		out.Result.Report.RunID = id
+	}
+	out.NotifyErr = sess.Push(ctx)
+	if out.Err != nil {
+		log.Error("签到执行出错", "err", out.Err)
+	} else {
+		log.Info("签到执行完成", "result", out.Result.Result,
+			"successful", out.Result.Stats.Accounts.Successful,
+			"skipped", out.Result.Stats.Accounts.Skipped,
+			"failed", out.Result.Stats.Accounts.Failed)
+	}
+	if out.NotifyErr != nil {
+		log.Warn("推送通知失败", "err", out.NotifyErr)
+	}
+	return out
+}
+
+// begin records the start of a run, returning nil without a history.
+func (c *Coordinator) begin(id string) *RunRecord {
+	if c.history == nil {
+		return nil
+	}
+	rec := &RunRecord{
+		ID:        id,
+		Status:    StatusRunning,
+		StartedAt: time.Now(),
+		Progress:  Progress{Total: len(c.svc.cfg.Tokens)},
+	}
+	_ = c.history.begin(rec)
+	return rec
+}
+
+// finish records the outcome of a run started at startedAt.
+func (c *Coordinator) finish(rec *RunRecord, out Outcome, startedAt time.Time) {
+	status := out.Result.Result
+	if out.Err != nil {
+		status = StatusError
+	}
+	metrics.Runs.With(status).Inc()
+	metrics.RunDuration.With().ObserveDuration(time.Since(startedAt))
+
+	if rec == nil {
+		return
+	}
+	now := time.Now()
+	rec.FinishedAt = &now
+	rec.Status = status
+	if out.Err != nil {
+		rec.Error = out.Err.Error()
+	} else {
+		rec.Result = &out.Result
+		rec.Progress.Current = rec.Progress.Total
+	}
+	if out.NotifyErr != nil {
+		rec.NotifyError = out.NotifyErr.Error()
+	}
+	_ = c.history.update(rec)
+}
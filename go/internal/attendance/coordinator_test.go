@@ -0,0 +1,58 @@
+package attendance
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"skland-daily-attendance-go/internal/notify"
+	"skland-daily-attendance-go/internal/skland/sklandtest"
+	"skland-daily-attendance-go/internal/storage"
+)
+
+// waitInflight waits until c has a run in flight.
+func waitInflight(t *testing.T, c *Coordinator) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		c.mu.Lock()
+		inflight := c.inflight != nil
+		c.mu.Unlock()
+		if inflight {
+			return
+		}
+	}
+	t.Fatal("no run in flight")
+}
+
+func TestCoordinatorStart(t *testing.T) {
+	sk := sklandtest.New(t)
+	sk.AddAccount("tok-a", sklandtest.Arknights("a1", "阿米娅"))
+	sk.SetDelay(50 * time.Millisecond)
+	svc := NewService(testConfig(t, "tok-a"), storage.NewMemoryStore(), sk.Option())
+	c := NewCoordinator(svc, notify.NewWebhookNotifier(nil, notify.Options{}), CoordinatorOptions{})
+
+	// A full run cannot join a selective one in flight.
+	done := make(chan Outcome)
+	go func() { done <- c.RunWith(context.Background(), RunOptions{Games: []string{"arknights"}}) }()
+	waitInflight(t, c)
+	if id, err := c.Start(); !errors.Is(err, ErrRunInProgress) || id != "" {
+		t.Errorf("Start during a selective run = %q, %v; want ErrRunInProgress", id, err)
+	}
+	if out := <-done; out.Err != nil {
+		t.Fatalf("selective run: %v", out.Err)
+	}
+
+	// Starts during a full run join it.
+	id, err := c.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := c.Start()
+	if err != nil || again != id {
+		t.Errorf("second Start = %q, %v; want to join %q", again, err, id)
+	}
+	if out := c.Run(context.Background()); out.RunID != id || out.Err != nil {
+		t.Errorf("Run = %s, %v; want to join %s", out.RunID, out.Err, id)
+	}
+}
@@ -0,0 +1,188 @@
+package attendance
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"skland-daily-attendance-go/internal/notify"
+	"skland-daily-attendance-go/internal/storage"
+)
+
+// Run statuses. Finished runs take the result of the run ("success" or
+// "failed"); runs that could not complete are "error".
+const (
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+	StatusError   = "error"
+)
+
+// RunRecord is the stored state of a run, from start to result.
+type RunRecord struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	StartedAt   time.Time  `json:"startedAt"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+	Progress    Progress   `json:"progress"`
+	Result      *Result    `json:"result,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	NotifyError string     `json:"notifyError,omitempty"`
+}
+
+// Progress tells how far a run has got through the accounts.
+type Progress struct {
+	// Total is the number of configured accounts.
+	Total int `json:"total"`
+	// Current is the 1-based number of the account being processed.
+	Current int `json:"current"`
+}
+
+// History retains the records of recent runs in the store.
+type History struct {
+	store storage.Store
+	limit int
+
+	mu sync.Mutex
+}
+
+// NewHistory creates a history keeping the last limit runs in store.
+func NewHistory(store storage.Store, limit int) *History {
+	return &History{store: store, limit: limit}
+}
+
+// Get returns the record of the run with the given ID.
+func (h *History) Get(id string) (*RunRecord, bool, error) {
+	v, ok, err := h.store.Get(storage.GenerateRunKey(id))
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	var rec RunRecord
+	if err := json.Unmarshal([]byte(v), &rec); err != nil {
+		return nil, false, err
+	}
+	return &rec, true, nil
+}
+
+// List returns up to limit records, newest first.
+func (h *History) List(limit int) ([]RunRecord, error) {
+	h.mu.Lock()
+	ids, err := h.index()
+	h.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	out := make([]RunRecord, 0, len(ids))
+	for _, id := range ids {
+		rec, ok, err := h.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, *rec)
+		}
+	}
+	return out, nil
+}
+
+// begin stores a new running record and adds it to the index, dropping the
+// records of the oldest runs beyond the limit. Stores implementing
+// storage.Updater update the index atomically, so that processes sharing
+// the store do not drop each other's runs.
+func (h *History) begin(rec *RunRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.save(rec); err != nil {
+		return err
+	}
+	var dropped []string
+	add := func(v string, ok bool) (string, error) {
+		var ids []string
+		if ok && v != "" {
+			if err := json.Unmarshal([]byte(v), &ids); err != nil {
+				return "", err
+			}
+		}
+		ids = append([]string{rec.ID}, ids...)
+		if h.limit > 0 && len(ids) > h.limit {
+			dropped = ids[h.limit:]
+			ids = ids[:h.limit]
+		}
+		b, err := json.Marshal(ids)
+		return string(b), err
+	}
+
+	var err error
+	if u, ok := h.store.(storage.Updater); ok {
+		err = u.Update(storage.RunIndexKey, add)
+	} else {
+		var v string
+		var found bool
+		if v, found, err = h.store.Get(storage.RunIndexKey); err == nil {
+			if v, err = add(v, found); err == nil {
+				err = h.store.Set(storage.RunIndexKey, v)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	for _, id := range dropped {
+		_ = h.store.Delete(storage.GenerateRunKey(id))
+	}
+	return nil
+}
+
+// update stores rec.
+func (h *History) update(rec *RunRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.save(rec)
+}
+
+func (h *History) save(rec *RunRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return h.store.Set(storage.GenerateRunKey(rec.ID), string(b))
+}
+
+func (h *History) index() ([]string, error) {
+	v, ok, err := h.store.Get(storage.RunIndexKey)
+	if err != nil || !ok || v == "" {
+		return nil, err
+	}
+	var ids []string
+	if err := json.Unmarshal([]byte(v), &ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// progressSession forwards messages to the notification session and reports
+// each account the run moves on to.
+type progressSession struct {
+	notify.Session
+	onAccount func(account int)
+
+	mu      sync.Mutex
+	current int
+}
+
+func (s *progressSession) Collect(msg notify.Message) {
+	s.Session.Collect(msg)
+	s.mu.Lock()
+	advanced := msg.Account > s.current
+	if advanced {
+		s.current = msg.Account
+	}
+	s.mu.Unlock()
+	if advanced {
+		s.onAccount(msg.Account)
+	}
+}
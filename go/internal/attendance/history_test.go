@@ -0,0 +1,75 @@
+package attendance
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"skland-daily-attendance-go/internal/storage"
+)
+
+// TestHistorySharedFileStore records runs from two processes sharing a
+// state file and checks that neither drops the runs of the other.
+func TestHistorySharedFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	const runs = 10
+
+	var wg sync.WaitGroup
+	for p := 0; p < 2; p++ {
+		store, err := storage.NewFileStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := NewHistory(store, 50)
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < runs; i++ {
+				rec := &RunRecord{ID: fmt.Sprintf("p%d-%d", p, i), Status: StatusRunning, StartedAt: time.Now()}
+				if err := h.begin(rec); err != nil {
+					t.Errorf("begin: %v", err)
+					return
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	store, err := storage.NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := NewHistory(store, 50).List(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2*runs {
+		t.Errorf("got %d runs, want %d", len(list), 2*runs)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	store := storage.NewMemoryStore()
+	h := NewHistory(store, 3)
+	for i := 0; i < 5; i++ {
+		if err := h.begin(&RunRecord{ID: fmt.Sprint(i), Status: StatusRunning}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := h.List(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, rec := range list {
+		ids = append(ids, rec.ID)
+	}
+	if fmt.Sprint(ids) != "[4 3 2]" {
+		t.Errorf("runs = %v, want [4 3 2]", ids)
+	}
+	if _, ok, _ := store.Get(storage.GenerateRunKey("0")); ok {
+		t.Error("record of dropped run 0 is still stored")
+	}
+}
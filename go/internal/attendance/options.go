@@ -0,0 +1,81 @@
+package attendance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"skland-daily-attendance-go/internal/config"
+	"skland-daily-attendance-go/internal/skland"
+)
+
+// RunOptions narrows a run down to some accounts and games, and controls
+// its side effects. The zero value runs every account and game.
+type RunOptions struct {
+	// Accounts selects accounts by name or 1-based number.
+	Accounts []string
+	// Games selects characters by game name or app code, e.g. "arknights".
+	Games []string
+	// DryRun signs in and checks the attendance status of each character,
+	// reporting which would be signed, without claiming rewards or
+	// recording the attendance.
+	DryRun bool
+	// Force processes accounts already recorded as attended today.
+	Force bool
+}
+
+// SelectAccounts returns the 0-based indexes of the accounts in cfg matched
+// by names, each a configured account name or a 1-based account number.
+// Empty names select every account.
+func SelectAccounts(cfg *config.Config, names []string) ([]int, error) {
+	if len(names) == 0 {
+		indexes := make([]int, len(cfg.Tokens))
+		for i := range indexes {
+			indexes[i] = i
+		}
+		return indexes, nil
+	}
+	selected := make(map[int]bool)
+	for _, name := range names {
+		idx, ok := findAccount(cfg, name)
+		if !ok {
+			return nil, fmt.Errorf("unknown account %q", name)
+		}
+		selected[idx] = true
+	}
+	// Keep the configured order whatever the order of names.
+	var indexes []int
+	for i := range cfg.Tokens {
+		if selected[i] {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes, nil
+}
+
+func findAccount(cfg *config.Config, name string) (int, bool) {
+	name = strings.TrimSpace(name)
+	for i := range cfg.Tokens {
+		if strings.EqualFold(cfg.AccountLabel(i), name) {
+			return i, true
+		}
+	}
+	if n, err := strconv.Atoi(name); err == nil && n >= 1 && n <= len(cfg.Tokens) {
+		return n - 1, true
+	}
+	return 0, false
+}
+
+// includesGame reports whether the character's game is selected.
+func (o RunOptions) includesGame(ch skland.AppBindingPlayer) bool {
+	if len(o.Games) == 0 {
+		return true
+	}
+	for _, g := range o.Games {
+		g = strings.TrimSpace(g)
+		if strings.EqualFold(g, ch.GameName) || strings.EqualFold(g, ch.AppCode) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package attendance
+
+import (
+	"time"
+
+	"skland-daily-attendance-go/internal/skland"
+)
+
+// Character outcomes in a RunReport.
+const (
+	CharacterSuccess         = "success"
+	CharacterAlreadyAttended = "already_attended"
+	CharacterWouldSign       = "would_sign"
+	CharacterFailed          = "failed"
+)
+
+// RunReport is the detailed, machine-readable record of a run. Its JSON
+// form uses stable snake_case keys.
+type RunReport struct {
+	RunID      string          `json:"run_id"`
+	Result     string          `json:"result"`
+	DryRun     bool            `json:"dry_run"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	DurationMS int64           `json:"duration_ms"`
+	Accounts   []AccountReport `json:"accounts"`
+	// Error is set when the run could not complete.
+	Error string `json:"error,omitempty"`
+}
+
+// AccountReport is the outcome of a single account.
+type AccountReport struct {
+	// Index is the 1-based number of the account.
+	Index int    `json:"index"`
+	Label string `json:"label"`
+	// Result is "success", "skipped" or "failed".
+	Result     string            `json:"result"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	DurationMS int64             `json:"duration_ms"`
+	Characters []CharacterReport `json:"characters"`
+	// Errors are the failures of the account outside any character, such
+	// as authentication.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// CharacterReport is the outcome of a single character.
+type CharacterReport struct {
+	GameID    int    `json:"game_id"`
+	GameName  string `json:"game_name"`
+	Character string `json:"character"`
+	// Result is one of the Character* outcomes.
+	Result     string   `json:"result"`
+	Message    string   `json:"message"`
+	Rewards    []string `json:"rewards"`
+	Error      string   `json:"error,omitempty"`
+	DurationMS int64    `json:"duration_ms"`
+}
+
+// Counts returns the number of characters by outcome.
+func (r *RunReport) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, a := range r.Accounts {
+		for _, c := range a.Characters {
+			counts[c.Result]++
+		}
+	}
+	return counts
+}
+
+// finish sets the end time and duration of the run.
+func (r *RunReport) finish(result string) {
+	r.Result = result
+	r.FinishedAt = time.Now()
+	r.DurationMS = r.FinishedAt.Sub(r.StartedAt).Milliseconds()
+}
+
+// finish sets the end time and duration of the account.
+func (a *AccountReport) finish(result string) {
+	a.Result = result
+	a.FinishedAt = time.Now()
+	a.DurationMS = a.FinishedAt.Sub(a.StartedAt).Milliseconds()
+	if a.Characters == nil {
+		a.Characters = []CharacterReport{}
+	}
+}
+
+// characterReport converts the result of a character attendance that took
+// elapsed.
+func characterReport(ch skland.AppBindingPlayer, res AttendanceResult, elapsed time.Duration) CharacterReport {
+	r := CharacterReport{
+		GameID:     ch.GameID,
+		GameName:   ch.GameName,
+		Character:  res.Character,
+		Result:     characterResult(res),
+		Message:    res.Message,
+		Rewards:    res.Rewards,
+		DurationMS: elapsed.Milliseconds(),
+	}
+	if r.Rewards == nil {
+		r.Rewards = []string{}
+	}
+	if res.HasError {
+		r.Error = res.Message
+	}
+	return r
+}
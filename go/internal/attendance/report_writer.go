@@ -0,0 +1,252 @@
+package attendance
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Report formats accepted by WriteReport.
+const (
+	ReportJSON     = "json"
+	ReportJUnit    = "junit"
+	ReportMarkdown = "markdown"
+)
+
+// ReportFormatForPath guesses the report format from a file extension,
+// defaulting to JSON.
+func ReportFormatForPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml":
+		return ReportJUnit
+	case ".md", ".markdown":
+		return ReportMarkdown
+	default:
+		return ReportJSON
+	}
+}
+
+// WriteReport writes r to w in the given format.
+func WriteReport(w io.Writer, format string, r *RunReport) error {
+	switch format {
+	case ReportJSON:
+		return r.WriteJSON(w)
+	case ReportJUnit:
+		return r.WriteJUnit(w)
+	case ReportMarkdown, "md":
+		return r.WriteMarkdown(w)
+	default:
+		return fmt.Errorf("unknown report format %q, want json, junit or markdown", format)
+	}
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *RunReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// JUnit XML schema, as understood by CI dashboards: one suite per account
+// and one case per character.
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the report as JUnit XML. Failed characters are
+// failures, account errors such as authentication are errors, and skipped
+// accounts and characters a dry run would sign are skipped.
+func (r *RunReport) WriteJUnit(w io.Writer) error {
+	suites := junitSuites{Name: "skland-attendance " + r.RunID, Time: seconds(r.DurationMS)}
+	if r.Error != "" {
+		suites.Suites = append(suites.Suites, junitSuite{
+			Name:      "run",
+			Tests:     1,
+			Errors:    1,
+			Time:      seconds(r.DurationMS),
+			Timestamp: r.StartedAt.Format("2006-01-02T15:04:05"),
+			Cases: []junitCase{{
+				Name:      "run",
+				Classname: "run",
+				Time:      seconds(r.DurationMS),
+				Error:     &junitFailure{Message: r.Error},
+			}},
+		})
+	}
+	for _, a := range r.Accounts {
+		suite := junitSuite{
+			Name:      fmt.Sprintf("%d %s", a.Index, a.Label),
+			Time:      seconds(a.DurationMS),
+			Timestamp: a.StartedAt.Format("2006-01-02T15:04:05"),
+		}
+		for _, e := range a.Errors {
+			suite.Cases = append(suite.Cases, junitCase{
+				Name:      "account",
+				Classname: a.Label,
+				Time:      "0",
+				Error:     &junitFailure{Message: e},
+			})
+			suite.Errors++
+		}
+		if a.Result == "skipped" {
+			suite.Cases = append(suite.Cases, junitCase{
+				Name:      "account",
+				Classname: a.Label,
+				Time:      "0",
+				Skipped:   &junitSkipped{Message: "今天已经签到过"},
+			})
+			suite.Skipped++
+		}
+		for _, c := range a.Characters {
+			tc := junitCase{
+				Name:      c.Character,
+				Classname: c.GameName,
+				Time:      seconds(c.DurationMS),
+				SystemOut: c.Message,
+			}
+			switch c.Result {
+			case CharacterFailed:
+				tc.Failure = &junitFailure{Message: c.Error}
+				suite.Failures++
+			case CharacterWouldSign:
+				tc.Skipped = &junitSkipped{Message: c.Message}
+				suite.Skipped++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suite.Tests = len(suite.Cases)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+	if r.Error != "" {
+		suites.Tests++
+		suites.Errors++
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(ms int64) string {
+	return fmt.Sprintf("%.3f", float64(ms)/1000)
+}
+
+// Labels of outcomes in the Markdown report.
+var markdownResults = map[string]string{
+	"success":                "✅ 成功",
+	"skipped":                "⏭️ 跳过",
+	"failed":                 "❌ 失败",
+	StatusError:              "❌ 出错",
+	CharacterAlreadyAttended: "☑️ 已签到",
+	CharacterWouldSign:       "📝 将会签到",
+}
+
+func markdownResult(result string) string {
+	if s, ok := markdownResults[result]; ok {
+		return s
+	}
+	return result
+}
+
+// markdownCell escapes a value for a Markdown table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// WriteMarkdown writes the report as Markdown: an overview, a table of
+// accounts and a table of characters.
+func (r *RunReport) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	title := "森空岛每日签到"
+	if r.DryRun {
+		title += "（演练）"
+	}
+	fmt.Fprintf(&b, "## %s：%s\n\n", title, markdownResult(r.Result))
+	if r.RunID != "" {
+		fmt.Fprintf(&b, "- 执行 ID：`%s`\n", r.RunID)
+	}
+	fmt.Fprintf(&b, "- 开始时间：%s\n", r.StartedAt.Format(time.DateTime))
+	fmt.Fprintf(&b, "- 耗时：%s\n", (time.Duration(r.DurationMS) * time.Millisecond).String())
+	if r.Error != "" {
+		fmt.Fprintf(&b, "- 错误：%s\n", markdownCell(r.Error))
+	}
+
+	if len(r.Accounts) > 0 {
+		b.WriteString("\n| 账号 | 结果 | 角色 | 耗时 | 说明 |\n| --- | --- | --- | --- | --- |\n")
+		for _, a := range r.Accounts {
+			fmt.Fprintf(&b, "| %d %s | %s | %d | %s | %s |\n", a.Index, markdownCell(a.Label),
+				markdownResult(a.Result), len(a.Characters),
+				(time.Duration(a.DurationMS) * time.Millisecond).String(),
+				markdownCell(strings.Join(a.Errors, "；")))
+		}
+	}
+
+	var rows []string
+	for _, a := range r.Accounts {
+		for _, c := range a.Characters {
+			rows = append(rows, fmt.Sprintf("| %s | %s | %s | %s | %s |", markdownCell(a.Label),
+				markdownCell(c.Character), markdownResult(c.Result),
+				markdownCell(strings.Join(c.Rewards, "、")), markdownCell(c.Error)))
+		}
+	}
+	if len(rows) > 0 {
+		b.WriteString("\n| 账号 | 角色 | 结果 | 奖励 | 错误 |\n| --- | --- | --- | --- | --- |\n")
+		b.WriteString(strings.Join(rows, "\n"))
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
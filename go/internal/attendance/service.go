@@ -2,62 +2,153 @@ package attendance
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
+	"time"
 
 	"skland-daily-attendance-go/internal/config"
+	"skland-daily-attendance-go/internal/events"
+	"skland-daily-attendance-go/internal/logging"
+	"skland-daily-attendance-go/internal/metrics"
 	"skland-daily-attendance-go/internal/notify"
 	"skland-daily-attendance-go/internal/skland"
 	"skland-daily-attendance-go/internal/storage"
+	"skland-daily-attendance-go/internal/tracing"
 )
 
-// Service coordinates attendance execution across accounts.
+// Service coordinates attendance execution across accounts. It holds no
+// per-run state, so concurrent runs are safe.
 type Service struct {
-	cfg      *config.Config
-	store    storage.Store
-	notifier notify.Notifier
+	cfg   *config.Config
+	store storage.Store
+	// clientOpts configure the Skland client of each run.
+	clientOpts []skland.Option
 }
 
-// NewService creates a new Service.
-func NewService(cfg *config.Config, store storage.Store, notifier notify.Notifier) *Service {
+// NewService creates a new Service whose runs create their Skland client
+// with clientOpts.
+func NewService(cfg *config.Config, store storage.Store, clientOpts ...skland.Option) *Service {
 	return &Service{
-		cfg:      cfg,
-		store:    store,
-		notifier: notifier,
+		cfg:        cfg,
+		store:      store,
+		clientOpts: clientOpts,
 	}
 }
 
-// Run executes daily attendance for all configured accounts.
-func (s *Service) Run(ctx context.Context) (Result, error) {
+// NewRunID returns a unique, time ordered identifier for a run.
+func NewRunID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
+
+// Run executes daily attendance for all configured accounts, collecting
+// notifications into sess, which may be nil. The caller pushes the session.
+// Progress events are sent to the publisher of ctx, if any.
+func (s *Service) Run(ctx context.Context, sess notify.Session) (Result, error) {
+	return s.RunWith(ctx, sess, RunOptions{})
+}
+
+// RunWith is like Run for the accounts and games selected by opts.
+func (s *Service) RunWith(ctx context.Context, sess notify.Session, opts RunOptions) (Result, error) {
+	indexes, err := SelectAccounts(s.cfg, opts.Accounts)
+	if err != nil {
+		return Result{Result: "failed"}, err
+	}
+	ctx, span := tracing.Start(ctx, "attendance.run", tracing.WithAttributes(
+		slog.Int("attendance.accounts", len(indexes)),
+		slog.Bool("attendance.dry_run", opts.DryRun),
+	))
+	defer span.End()
+	if span != nil {
+		ctx = logging.With(ctx, "trace_id", span.TraceID().String())
+	}
+	runCtx := ctx
+	emitRun := func(msg notify.Message) {
+		logMessage(runCtx, msg)
+		if sess != nil {
+			sess.Collect(msg)
+		}
+	}
+	startedAt := time.Now()
+	report := &RunReport{DryRun: opts.DryRun, StartedAt: startedAt, Accounts: []AccountReport{}}
 	stats := ExecutionStats{
 		CharactersByGame: make(map[int]*GameStats),
 	}
-	stats.Accounts.Total = len(s.cfg.Tokens)
+	stats.Accounts.Total = len(indexes)
+	events.Publish(ctx, events.Event{Type: events.RunStarted, Total: len(indexes)})
 
-	if len(s.cfg.Tokens) == 0 {
-		if s.notifier != nil {
-			s.notifier.Collect(notify.Message{Text: "未配置任何账号，跳过签到任务"})
-		}
-		return Result{Result: "success", Stats: stats}, nil
+	if len(indexes) == 0 {
+		emitRun(notify.Message{Text: "未配置任何账号，跳过签到任务", Stage: notify.StageRun})
+		events.Publish(ctx, events.Event{Type: events.RunFinished, Result: "success"})
+		report.finish("success")
+		return Result{Result: "success", Stats: stats, Report: report}, nil
 	}
 
-	client := skland.NewClient()
+	client := skland.NewClient(s.clientOpts...)
 	hasFailed := false
+	var accountResults []notify.AccountResult
 
-	for idx, token := range s.cfg.Tokens {
+	for _, idx := range indexes {
+		token := s.cfg.Tokens[idx]
 		accountNumber := idx + 1
-		header := fmt.Sprintf("--- 账号 %d/%d ---", accountNumber, len(s.cfg.Tokens))
-		if s.notifier != nil {
-			s.notifier.Collect(notify.Message{Text: header})
-			s.notifier.Collect(notify.Message{Text: "开始处理..."})
+		accountLabel := s.cfg.AccountLabel(idx)
+		// API calls and messages of the account carry its attributes.
+		ctx, accountSpan := tracing.Start(runCtx, "attendance.account", tracing.WithAttributes(
+			slog.Int("attendance.account", accountNumber),
+			slog.String("attendance.account_label", accountLabel),
+		))
+		ctx = logging.With(ctx, "account", accountNumber, "account_label", accountLabel)
+		accountReport := AccountReport{Index: accountNumber, Label: accountLabel, StartedAt: time.Now()}
+		emit := func(msg notify.Message) {
+			msg.Account = accountNumber
+			msg.AccountLabel = accountLabel
+			if sess != nil {
+				sess.Collect(msg)
+			}
+			logMessage(ctx, msg)
 		}
+		publish := func(e events.Event) {
+			e.Account = accountNumber
+			e.AccountLabel = accountLabel
+			events.Publish(ctx, e)
+		}
+		fail := func(stage notify.Stage, kind notify.ErrorKind, text string, err error) {
+			msg := notify.Message{
+				Text:      fmt.Sprintf("%s: %v", text, err),
+				Level:     notify.LevelError,
+				Stage:     stage,
+				ErrorKind: errorKind(err, kind),
+			}
+			emit(msg)
+			publish(events.Event{Type: events.AccountError, Text: msg.Text})
+			accountReport.Errors = append(accountReport.Errors, msg.Text)
+			accountSpan.RecordError(fmt.Errorf("%s: %w", text, err))
+		}
+
+		emit(notify.Message{Text: "开始处理...", Level: notify.LevelDebug, Stage: notify.StageAccount})
+		publish(events.Event{Type: events.AccountStarted})
+		accountGames := make(map[int]*GameStats)
+		accountResult := notify.AccountResult{Index: accountNumber, Label: accountLabel}
 
 		attendedKey, err := storage.GenerateAttendanceKey(token)
-		if err == nil {
+		// A dry run checks the account even when it was recorded as attended.
+		if err == nil && !opts.DryRun && !opts.Force {
 			if ok, _ := s.store.HasAttended(attendedKey); ok {
-				if s.notifier != nil {
-					s.notifier.Collect(notify.Message{Text: "今天已经签到过，跳过"})
-				}
+				emit(notify.Message{Text: "今天已经签到过，跳过", Stage: notify.StageAccount})
 				stats.Accounts.Skipped++
+				accountResult.Result = "skipped"
+				accountResults = append(accountResults, accountResult)
+				publish(events.Event{Type: events.AccountFinished, Result: "skipped"})
+				metrics.AccountResults.With("skipped").Inc()
+				accountSpan.SetAttributes(slog.String("attendance.result", "skipped"))
+				accountSpan.End()
+				accountReport.finish("skipped")
+				report.Accounts = append(report.Accounts, accountReport)
 				continue
 			}
 		}
@@ -67,58 +158,91 @@ func (s *Service) Run(ctx context.Context) (Result, error) {
 		// Exchange token for authorize code and sign in.
 		code, err := client.GrantAuthorizeCode(ctx, token)
 		if err != nil {
-			if s.notifier != nil {
-				s.notifier.Collect(notify.Message{
-					Text:   fmt.Sprintf("获取授权码失败: %v", err),
-					IsError: true,
-				})
-			}
+			fail(notify.StageAuth, notify.ErrorAuth, "获取授权码失败", err)
 			accountHasError = true
 		} else {
 			sessionToken, err := client.SignIn(ctx, code)
 			if err != nil {
-				if s.notifier != nil {
-					s.notifier.Collect(notify.Message{
-						Text:   fmt.Sprintf("登录失败: %v", err),
-						IsError: true,
-					})
-				}
+				fail(notify.StageAuth, notify.ErrorAuth, "登录失败", err)
 				accountHasError = true
 			} else {
 				// Get bindings
 				bindings, err := client.GetBinding(ctx, sessionToken)
 				if err != nil {
-					if s.notifier != nil {
-						s.notifier.Collect(notify.Message{
-							Text:   fmt.Sprintf("获取绑定角色失败: %v", err),
-							IsError: true,
-						})
-					}
+					fail(notify.StageBinding, notify.ErrorBinding, "获取绑定角色失败", err)
 					accountHasError = true
 				} else {
 					characters := flattenCharacters(bindings)
 					for _, ch := range characters {
+						if !opts.includesGame(ch) {
+							continue
+						}
 						gameStats := stats.CharactersByGame[ch.GameID]
 						if gameStats == nil {
-							gameStats = &GameStats{}
+							gameStats = &GameStats{Name: ch.GameName}
 							stats.CharactersByGame[ch.GameID] = gameStats
 						}
 						gameStats.Total++
+						accountGame := accountGames[ch.GameID]
+						if accountGame == nil {
+							accountGame = &GameStats{Name: ch.GameName}
+							accountGames[ch.GameID] = accountGame
+						}
+						accountGame.Total++
 
-						res := AttendCharacter(ctx, client, ch, s.cfg.MaxRetries, ch.GameName)
-						if s.notifier != nil {
-							s.notifier.Collect(notify.Message{
-								Text:    res.Message,
-								IsError: res.HasError,
-							})
+						charStarted := time.Now()
+						charCtx, charSpan := tracing.Start(ctx, "attendance.character",
+							tracing.WithAttributes(slog.String("attendance.game", ch.GameName)))
+						res := AttendCharacter(charCtx, client, ch, ch.GameName, CharacterOptions{
+							SessionToken: sessionToken,
+							MaxRetries:   s.cfg.MaxRetries,
+							Location:     s.cfg.Location,
+							DryRun:       opts.DryRun,
+						})
+						charSpan.SetAttributes(slog.String("attendance.result", characterResult(res)))
+						if res.HasError {
+							charSpan.SetStatus(tracing.StatusError, res.Message)
+						}
+						charSpan.End()
+						accountReport.Characters = append(accountReport.Characters, characterReport(ch, res, time.Since(charStarted)))
+						msg := notify.Message{
+							Text:      res.Message,
+							Stage:     notify.StageAttendance,
+							GameID:    ch.GameID,
+							GameName:  ch.GameName,
+							Character: res.Character,
+							Rewards:   res.Rewards,
+						}
+						if res.HasError {
+							msg.Level = notify.LevelError
+							msg.ErrorKind = errorKind(ctx.Err(), notify.ErrorAttendance)
+						}
+						emit(msg)
+						publish(events.Event{
+							Type:      events.Character,
+							GameName:  ch.GameName,
+							Character: res.Character,
+							Result:    characterResult(res),
+							Text:      res.Message,
+							Rewards:   res.Rewards,
+						})
+
+						if !opts.DryRun {
+							metrics.Characters.With(ch.GameName, characterMetric(res)).Inc()
 						}
 						if res.HasError {
 							gameStats.Failed++
+							accountGame.Failed++
 							accountHasError = true
 						} else if res.Success {
 							gameStats.Succeeded++
+							accountGame.Succeeded++
+						} else if res.WouldSign {
+							gameStats.WouldSign++
+							accountGame.WouldSign++
 						} else {
 							gameStats.AlreadyAttended++
+							accountGame.AlreadyAttended++
 						}
 					}
 				}
@@ -126,55 +250,128 @@ func (s *Service) Run(ctx context.Context) (Result, error) {
 		}
 
 		if !accountHasError {
-			if attendedKey != "" {
+			// A run limited to some games has not signed the whole account.
+			if attendedKey != "" && !opts.DryRun && len(opts.Games) == 0 {
 				_ = s.store.MarkAttended(attendedKey)
 			}
 			stats.Accounts.Successful++
+			accountResult.Result = "success"
+			if !opts.DryRun {
+				metrics.LastAccountSuccess.With(accountLabel).SetToCurrentTime()
+			}
 		} else {
 			hasFailed = true
 			stats.Accounts.Failed++
 			stats.Accounts.FailedIndexes = append(stats.Accounts.FailedIndexes, accountNumber)
+			accountResult.Result = "failed"
 		}
-	}
-
-	// Summary
-	if s.notifier != nil {
-		s.notifier.Collect(notify.Message{Text: "========== 执行摘要 =========="})
-		s.notifier.Collect(notify.Message{Text: fmt.Sprintf("账号统计:")})
-		s.notifier.Collect(notify.Message{Text: fmt.Sprintf("  • 总数: %d", stats.Accounts.Total)})
-		s.notifier.Collect(notify.Message{Text: fmt.Sprintf("  • 成功: %d", stats.Accounts.Successful)})
-		s.notifier.Collect(notify.Message{Text: fmt.Sprintf("  • 跳过: %d", stats.Accounts.Skipped)})
-		if stats.Accounts.Failed > 0 {
-			s.notifier.Collect(notify.Message{
-				Text:   fmt.Sprintf("  • 失败: %d (账号 #%v)", stats.Accounts.Failed, stats.Accounts.FailedIndexes),
-				IsError: true,
-			})
-		}
-
-		for gameID, st := range stats.CharactersByGame {
-			s.notifier.Collect(notify.Message{Text: fmt.Sprintf("【%d】角色统计:", gameID)})
-			s.notifier.Collect(notify.Message{Text: fmt.Sprintf("  • 总数: %d", st.Total)})
-			s.notifier.Collect(notify.Message{Text: fmt.Sprintf("  • 本次签到成功: %d", st.Succeeded)})
-			s.notifier.Collect(notify.Message{Text: fmt.Sprintf("  • 今天已签到: %d", st.AlreadyAttended)})
-			if st.Failed > 0 {
-				s.notifier.Collect(notify.Message{
-					Text:   fmt.Sprintf("  • 签到失败: %d", st.Failed),
-					IsError: true,
-				})
-			}
+		accountResult.Games = gameSummaries(accountGames)
+		accountResults = append(accountResults, accountResult)
+		publish(events.Event{Type: events.AccountFinished, Result: accountResult.Result})
+		metrics.AccountResults.With(accountResult.Result).Inc()
+		accountReport.finish(accountResult.Result)
+		report.Accounts = append(report.Accounts, accountReport)
+		accountSpan.SetAttributes(slog.String("attendance.result", accountResult.Result))
+		if accountHasError {
+			accountSpan.SetStatus(tracing.StatusError, "account failed")
 		}
+		accountSpan.End()
 	}
 
 	result := "success"
 	if hasFailed {
 		result = "failed"
 	}
+	if opts.DryRun {
+		var wouldSign, attended int
+		for _, g := range stats.CharactersByGame {
+			wouldSign += g.WouldSign
+			attended += g.AlreadyAttended
+		}
+		emitRun(notify.Message{
+			Text:  fmt.Sprintf("演练完成：%d 个角色将会签到，%d 个角色今天已签到", wouldSign, attended),
+			Stage: notify.StageRun,
+		})
+	}
+	if sess != nil {
+		sess.Summarize(stats.summary(result, startedAt, accountResults))
+	}
+	events.Publish(ctx, events.Event{Type: events.RunFinished, Result: result})
+	span.SetAttributes(slog.String("attendance.result", result))
+	if hasFailed {
+		span.SetStatus(tracing.StatusError, "some accounts failed")
+	}
+	report.finish(result)
 	return Result{
 		Result: result,
 		Stats:  stats,
+		Report: report,
 	}, nil
 }
 
+// logMessage logs a notification message at the matching level.
+func logMessage(ctx context.Context, msg notify.Message) {
+	level := slog.LevelInfo
+	switch msg.Level {
+	case notify.LevelDebug:
+		level = slog.LevelDebug
+	case notify.LevelWarn:
+		level = slog.LevelWarn
+	case notify.LevelError:
+		level = slog.LevelError
+	}
+	attrs := []slog.Attr{slog.String("stage", string(msg.Stage))}
+	if msg.GameName != "" {
+		attrs = append(attrs, slog.String("game", msg.GameName))
+	}
+	if msg.Character != "" {
+		attrs = append(attrs, slog.String("character", msg.Character))
+	}
+	if len(msg.Rewards) > 0 {
+		attrs = append(attrs, slog.Any("rewards", msg.Rewards))
+	}
+	if msg.ErrorKind != "" {
+		attrs = append(attrs, slog.String("error_kind", string(msg.ErrorKind)))
+	}
+	logging.FromContext(ctx).LogAttrs(ctx, level, msg.Text, attrs...)
+}
+
+// characterResult names the outcome of a character attendance.
+func characterResult(res AttendanceResult) string {
+	switch {
+	case res.HasError:
+		return CharacterFailed
+	case res.Success:
+		return CharacterSuccess
+	case res.WouldSign:
+		return CharacterWouldSign
+	default:
+		return CharacterAlreadyAttended
+	}
+}
+
+// characterMetric names the outcome of a character attendance after the
+// GameStats field counting it.
+func characterMetric(res AttendanceResult) string {
+	if r := characterResult(res); r != CharacterSuccess {
+		return r
+	}
+	return "succeeded"
+}
+
+// errorKind classifies err, reporting timeouts separately from the
+// stage-specific fallback kind.
+func errorKind(err error, fallback notify.ErrorKind) notify.ErrorKind {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return notify.ErrorTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return notify.ErrorTimeout
+	}
+	return fallback
+}
+
 // flattenCharacters filters and flattens binding items to characters we support.
 func flattenCharacters(list []skland.BindingItem) []skland.AppBindingPlayer {
 	available := map[string]struct{}{
@@ -186,8 +383,12 @@ func flattenCharacters(list []skland.BindingItem) []skland.AppBindingPlayer {
 		if _, ok := available[item.AppCode]; !ok {
 			continue
 		}
-		result = append(result, item.BindingList...)
+		for _, ch := range item.BindingList {
+			if ch.AppCode == "" {
+				ch.AppCode = item.AppCode
+			}
+			result = append(result, ch)
+		}
 	}
 	return result
 }
-
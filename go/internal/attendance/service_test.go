@@ -0,0 +1,168 @@
+package attendance
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"skland-daily-attendance-go/internal/config"
+	"skland-daily-attendance-go/internal/notify"
+	"skland-daily-attendance-go/internal/skland/sklandtest"
+	"skland-daily-attendance-go/internal/storage"
+	"skland-daily-attendance-go/internal/tracing"
+)
+
+func testConfig(t *testing.T, tokens ...string) *config.Config {
+	t.Helper()
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &config.Config{Tokens: tokens, MaxRetries: 1, Location: loc}
+}
+
+// spanTree indexes exported spans by ID and by parent.
+type spanTree struct {
+	byID     map[tracing.SpanID]tracing.SpanData
+	children map[tracing.SpanID][]tracing.SpanData
+}
+
+func newSpanTree(spans []tracing.SpanData) spanTree {
+	tree := spanTree{
+		byID:     make(map[tracing.SpanID]tracing.SpanData),
+		children: make(map[tracing.SpanID][]tracing.SpanData),
+	}
+	for _, s := range spans {
+		tree.byID[s.SpanID] = s
+		tree.children[s.Parent] = append(tree.children[s.Parent], s)
+	}
+	return tree
+}
+
+// named returns the children of parent with the given name.
+func (tree spanTree) named(parent tracing.SpanID, name string) []tracing.SpanData {
+	var out []tracing.SpanData
+	for _, s := range tree.children[parent] {
+		if s.Name == name {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func attr(s tracing.SpanData, key string) string {
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			return a.Value.String()
+		}
+	}
+	return ""
+}
+
+func TestRunSpans(t *testing.T) {
+	exp := &tracing.InMemoryExporter{}
+	tracing.SetDefault(tracing.NewProvider(exp, tracing.ProviderOptions{Synchronous: true}))
+	t.Cleanup(func() { tracing.SetDefault(nil) })
+
+	sk := sklandtest.New(t)
+	sk.AddAccount("tok-a", sklandtest.Arknights("a1", "阿米娅"))
+	sk.AddAccount("tok-b", sklandtest.Endfield("b1", "r1", "管理员"))
+	hook := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer hook.Close()
+
+	svc := NewService(testConfig(t, "tok-a", "tok-b"), storage.NewMemoryStore(), sk.Option())
+	sess := notify.NewWebhookNotifier([]string{hook.URL}, notify.Options{}).Begin("run")
+	ctx := context.Background()
+	if _, err := svc.Run(ctx, sess); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if err := sess.Push(ctx); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	tree := newSpanTree(exp.Spans())
+	runs := tree.named(tracing.SpanID{}, "attendance.run")
+	if len(runs) != 1 {
+		t.Fatalf("got %d root attendance.run spans, want 1", len(runs))
+	}
+	run := runs[0]
+	if got := attr(run, "attendance.accounts"); got != "2" {
+		t.Errorf("attendance.accounts = %s, want 2", got)
+	}
+
+	accounts := tree.named(run.SpanID, "attendance.account")
+	if len(accounts) != 2 {
+		t.Fatalf("got %d attendance.account spans under the run, want 2", len(accounts))
+	}
+	wantCalls := []string{"POST /api/auth/grant", "POST /api/auth/login", "GET /api/game/player/binding"}
+	claims := map[string]string{
+		"1": "POST /api/v1/game/attendance",
+		"2": "POST /web/v1/game/endfield/attendance",
+	}
+	for _, account := range accounts {
+		if account.TraceID != run.TraceID {
+			t.Errorf("account span is in trace %s, want %s", account.TraceID, run.TraceID)
+		}
+		for _, name := range wantCalls {
+			calls := tree.named(account.SpanID, name)
+			if len(calls) != 1 {
+				t.Errorf("account %s: got %d %q spans, want 1", attr(account, "attendance.account"), len(calls), name)
+				continue
+			}
+			if calls[0].Kind != tracing.KindClient || attr(calls[0], "http.response.status_code") != "200" {
+				t.Errorf("%s span: kind %d, status %q", name, calls[0].Kind, attr(calls[0], "http.response.status_code"))
+			}
+		}
+		chars := tree.named(account.SpanID, "attendance.character")
+		if len(chars) != 1 {
+			t.Errorf("account %s: got %d attendance.character spans, want 1", attr(account, "attendance.account"), len(chars))
+			continue
+		}
+		if got := attr(chars[0], "attendance.result"); got != "success" {
+			t.Errorf("attendance.result = %q, want success", got)
+		}
+		claim := claims[attr(account, "attendance.account")]
+		if len(tree.named(chars[0].SpanID, claim)) != 1 {
+			t.Errorf("character span of account %s lacks a %q child", attr(account, "attendance.account"), claim)
+		}
+	}
+
+	pushes := tree.named(tracing.SpanID{}, "notify.push")
+	if len(pushes) != 1 {
+		t.Fatalf("got %d notify.push spans, want 1", len(pushes))
+	}
+	if pushes[0].Status == tracing.StatusError {
+		t.Errorf("notify.push failed: %s", pushes[0].StatusMsg)
+	}
+	if len(tree.named(pushes[0].SpanID, "notify.deliver")) != 1 {
+		t.Error("notify.push lacks a notify.deliver child")
+	}
+}
+
+func TestRunSpansRecordErrors(t *testing.T) {
+	exp := &tracing.InMemoryExporter{}
+	tracing.SetDefault(tracing.NewProvider(exp, tracing.ProviderOptions{Synchronous: true}))
+	t.Cleanup(func() { tracing.SetDefault(nil) })
+	sk := sklandtest.New(t)
+
+	svc := NewService(testConfig(t, "unknown"), storage.NewMemoryStore(), sk.Option())
+	if _, err := svc.Run(context.Background(), nil); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	tree := newSpanTree(exp.Spans())
+	runs := tree.named(tracing.SpanID{}, "attendance.run")
+	if len(runs) != 1 {
+		t.Fatalf("got %d attendance.run spans, want 1", len(runs))
+	}
+	accounts := tree.named(runs[0].SpanID, "attendance.account")
+	if len(accounts) != 1 || accounts[0].Status != tracing.StatusError {
+		t.Fatalf("account spans = %+v, want one failed span", accounts)
+	}
+	grants := tree.named(accounts[0].SpanID, "POST /api/auth/grant")
+	if len(grants) != 1 || grants[0].Status != tracing.StatusError {
+		t.Errorf("grant spans = %+v, want one failed span", grants)
+	}
+}
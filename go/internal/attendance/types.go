@@ -1,28 +1,144 @@
 package attendance
 
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"skland-daily-attendance-go/internal/notify"
+)
+
 // GameStats corresponds to per-game statistics.
 type GameStats struct {
-	Total           int
-	Succeeded       int
-	AlreadyAttended int
-	Failed          int
+	Name            string `json:"name"`
+	Total           int    `json:"total"`
+	Succeeded       int    `json:"succeeded"`
+	AlreadyAttended int    `json:"already_attended"`
+	Failed          int    `json:"failed"`
+	// WouldSign counts the characters a dry run found not yet attended.
+	WouldSign int `json:"would_sign,omitempty"`
 }
 
-// ExecutionStats corresponds to overall execution statistics.
+// ExecutionStats corresponds to overall execution statistics. Like
+// RunReport, it is encoded with snake_case keys; the envelopes embedding it
+// (run records, HTTP and cloud function responses) keep their own
+// camelCase keys.
 type ExecutionStats struct {
-	Accounts struct {
-		Total         int
-		Successful    int
-		Skipped       int
-		Failed        int
-		FailedIndexes []int
+	Accounts         AccountStats       `json:"accounts"`
+	CharactersByGame map[int]*GameStats `json:"characters_by_game"`
+}
+
+// AccountStats counts the accounts of a run by outcome.
+type AccountStats struct {
+	Total         int   `json:"total"`
+	Successful    int   `json:"successful"`
+	Skipped       int   `json:"skipped"`
+	Failed        int   `json:"failed"`
+	FailedIndexes []int `json:"failed_indexes"`
+}
+
+// The stats of runs stored in the history before the keys were snake_case
+// use Go field names or camelCase; encoding/json matches both to a
+// camelCase key case-insensitively, so the decoders below fall back to it.
+
+// UnmarshalJSON decodes stats, including those of older run records.
+func (s *ExecutionStats) UnmarshalJSON(b []byte) error {
+	type plain ExecutionStats
+	var v struct {
+		plain
+		Legacy map[int]*GameStats `json:"charactersByGame"`
 	}
-	CharactersByGame map[int]*GameStats
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*s = ExecutionStats(v.plain)
+	if s.CharactersByGame == nil {
+		s.CharactersByGame = v.Legacy
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes account stats, including those of older run
+// records.
+func (s *AccountStats) UnmarshalJSON(b []byte) error {
+	type plain AccountStats
+	var v struct {
+		plain
+		Legacy []int `json:"failedIndexes"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*s = AccountStats(v.plain)
+	if s.FailedIndexes == nil {
+		s.FailedIndexes = v.Legacy
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes game stats, including those of older run records.
+func (s *GameStats) UnmarshalJSON(b []byte) error {
+	type plain GameStats
+	var v struct {
+		plain
+		LegacyAlreadyAttended *int `json:"alreadyAttended"`
+		LegacyWouldSign       *int `json:"wouldSign"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*s = GameStats(v.plain)
+	if v.LegacyAlreadyAttended != nil && s.AlreadyAttended == 0 {
+		s.AlreadyAttended = *v.LegacyAlreadyAttended
+	}
+	if v.LegacyWouldSign != nil && s.WouldSign == 0 {
+		s.WouldSign = *v.LegacyWouldSign
+	}
+	return nil
 }
 
 // Result is the result of a full attendance run.
 type Result struct {
-	Result string          `json:"result"` // "success" or "failed"
-	Stats  ExecutionStats  `json:"stats"`
+	Result string         `json:"result"` // "success" or "failed"
+	Stats  ExecutionStats `json:"stats"`
+	// Report details every account and character of the run. It is not
+	// part of the JSON form of Result, which is kept compact for the run
+	// history; see RunReport for its own encoding.
+	Report *RunReport `json:"-"`
+}
+
+// summary converts the stats into the notification summary.
+func (s ExecutionStats) summary(result string, startedAt time.Time, accounts []notify.AccountResult) notify.Summary {
+	sum := notify.Summary{
+		Result:     result,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+		Accounts: notify.AccountSummary{
+			Total:         s.Accounts.Total,
+			Successful:    s.Accounts.Successful,
+			Skipped:       s.Accounts.Skipped,
+			Failed:        s.Accounts.Failed,
+			FailedIndexes: s.Accounts.FailedIndexes,
+		},
+		Games:          gameSummaries(s.CharactersByGame),
+		AccountResults: accounts,
+	}
+	return sum
 }
 
+// gameSummaries converts per-game stats into summaries ordered by game id.
+func gameSummaries(games map[int]*GameStats) []notify.GameSummary {
+	var out []notify.GameSummary
+	for gameID, st := range games {
+		out = append(out, notify.GameSummary{
+			GameID:          gameID,
+			GameName:        st.Name,
+			Total:           st.Total,
+			Succeeded:       st.Succeeded,
+			AlreadyAttended: st.AlreadyAttended,
+			Failed:          st.Failed,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].GameID < out[j].GameID })
+	return out
+}
@@ -0,0 +1,61 @@
+package attendance
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExecutionStatsJSON(t *testing.T) {
+	stats := ExecutionStats{CharactersByGame: map[int]*GameStats{
+		1: {Name: "明日方舟", Total: 2, Succeeded: 1, AlreadyAttended: 1, WouldSign: 1},
+	}}
+	stats.Accounts = AccountStats{Total: 2, Successful: 1, Failed: 1, FailedIndexes: []int{2}}
+
+	b, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"accounts":{"total":2,"successful":1,"skipped":0,"failed":1,"failed_indexes":[2]},` +
+		`"characters_by_game":{"1":{"name":"明日方舟","total":2,"succeeded":1,"already_attended":1,"failed":0,"would_sign":1}}}`
+	if string(b) != want {
+		t.Errorf("Marshal =\n%s\nwant\n%s", b, want)
+	}
+
+	var got ExecutionStats
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, stats) {
+		t.Errorf("round trip = %+v, want %+v", got, stats)
+	}
+}
+
+func TestExecutionStatsLegacyJSON(t *testing.T) {
+	want := ExecutionStats{CharactersByGame: map[int]*GameStats{
+		1: {Name: "明日方舟", Total: 3, Succeeded: 1, AlreadyAttended: 2, WouldSign: 1},
+	}}
+	want.Accounts = AccountStats{Total: 2, Successful: 1, Failed: 1, FailedIndexes: []int{2}}
+
+	for name, raw := range map[string]string{
+		// Go field names, stored before the fields had JSON tags.
+		"go": `{"Accounts":{"Total":2,"Successful":1,"Skipped":0,"Failed":1,"FailedIndexes":[2]},` +
+			`"CharactersByGame":{"1":{"Name":"明日方舟","Total":3,"Succeeded":1,"AlreadyAttended":2,"Failed":0,"WouldSign":1}}}`,
+		"camel": `{"accounts":{"total":2,"successful":1,"skipped":0,"failed":1,"failedIndexes":[2]},` +
+			`"charactersByGame":{"1":{"name":"明日方舟","total":3,"succeeded":1,"alreadyAttended":2,"failed":0,"wouldSign":1}}}`,
+	} {
+		var got ExecutionStats
+		if err := json.Unmarshal([]byte(raw), &got); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			b, _ := json.Marshal(got)
+			t.Errorf("%s: decoded %s", name, b)
+		}
+		b, _ := json.Marshal(got)
+		if strings.Contains(string(b), "Attended\"") || strings.Contains(string(b), "ByGame") {
+			t.Errorf("%s: re-encoded with legacy keys: %s", name, b)
+		}
+	}
+}
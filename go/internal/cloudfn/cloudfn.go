@@ -0,0 +1,269 @@
+// Package cloudfn runs attendance for cloud function invocations. It holds
+// the wiring shared by the AWS Lambda, Aliyun FC and Tencent SCF entry
+// points, which only translate their platform's events into a Request and
+// the Response back.
+package cloudfn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"skland-daily-attendance-go/internal/attendance"
+	"skland-daily-attendance-go/internal/config"
+	"skland-daily-attendance-go/internal/logging"
+	"skland-daily-attendance-go/internal/notify"
+	"skland-daily-attendance-go/internal/skland"
+	"skland-daily-attendance-go/internal/storage"
+	"skland-daily-attendance-go/internal/tracing"
+)
+
+const (
+	// runTimeout bounds a run, including notification delivery.
+	runTimeout = 10 * time.Minute
+	// traceFlushTimeout bounds the export of the spans of an invocation,
+	// which must finish before the function is frozen.
+	traceFlushTimeout = 5 * time.Second
+)
+
+// ErrInvalidRequest marks errors caused by the invocation input.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// InvalidRequest marks err, caused by the invocation input, with
+// ErrInvalidRequest.
+func InvalidRequest(err error) error {
+	return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+}
+
+// Request selects what an invocation runs. The zero value runs every
+// account.
+type Request struct {
+	// Accounts selects accounts by name or 1-based number.
+	Accounts []string `json:"accounts,omitempty"`
+	// Games selects games by app code, e.g. "arknights", or name.
+	Games  []string `json:"games,omitempty"`
+	DryRun bool     `json:"dryRun,omitempty"`
+	// Force processes accounts already recorded as attended today.
+	Force bool `json:"force,omitempty"`
+}
+
+// Response is the result of an invocation. Over HTTP it is the JSON body of
+// the response.
+type Response struct {
+	Result string `json:"result"`
+	RunID  string `json:"runId,omitempty"`
+	DryRun bool   `json:"dryRun,omitempty"`
+	// Stats is absent when the run could not start.
+	Stats *attendance.ExecutionStats `json:"stats,omitempty"`
+	// Report details every account and character, with the snake_case
+	// keys of attendance.RunReport.
+	Report      *attendance.RunReport `json:"report,omitempty"`
+	NotifyError string                `json:"notifyError,omitempty"`
+	Error       string                `json:"error,omitempty"`
+}
+
+// DecodeRequest decodes a JSON Request, rejecting unknown fields so that a
+// misspelt option is not silently ignored. An empty input or null is the
+// zero Request.
+func DecodeRequest(raw []byte) (Request, error) {
+	var req Request
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return req, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return Request{}, InvalidRequest(err)
+	}
+	return req, nil
+}
+
+// HTTPRequest reads a Request from an HTTP request: the JSON body when
+// there is one, otherwise the query string, looked up with query, e.g.
+// ?accounts=alice,bob&games=arknights&dryRun=true.
+func HTTPRequest(body []byte, query func(key string) string) (Request, error) {
+	if len(bytes.TrimSpace(body)) > 0 {
+		return DecodeRequest(body)
+	}
+
+	var req Request
+	req.Accounts = splitList(query("accounts"))
+	req.Games = splitList(query("games"))
+	for key, dst := range map[string]*bool{"dryRun": &req.DryRun, "force": &req.Force} {
+		if v := query(key); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return Request{}, fmt.Errorf("%w: invalid %s %q", ErrInvalidRequest, key, v)
+			}
+			*dst = b
+		}
+	}
+	return req, nil
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// StatusCode is the HTTP status for the error returned by Invoke: 409 when
+// a run with other options is in flight.
+func StatusCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, attendance.ErrRunInProgress):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Function runs the invocations of a function instance. Its logger, tracer,
+// store and notifier are created once, when the instance starts, and are
+// shared by every invocation: warm instances of FC HTTP functions and SCF
+// web functions serve concurrent requests, which the coordinator coalesces
+// into a single run, as in the HTTP mode of the command.
+type Function struct {
+	cfg    *config.Config
+	svc    *attendance.Service
+	coord  *attendance.Coordinator
+	tracer *tracing.Provider
+}
+
+// New creates the Function for cfg, installing the logger and the tracer
+// it describes as the process defaults. Entry points call it once, before
+// serving invocations, and Shutdown when the instance stops. The Skland
+// client of each run is created with clientOpts.
+func New(cfg *config.Config, clientOpts ...skland.Option) (*Function, error) {
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := logging.New(os.Stderr, logging.Options{Format: cfg.LogFormat, Level: level, Secrets: cfg.Secrets()})
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+
+	store, err := storage.Open(cfg.StorePath)
+	if err != nil {
+		return nil, err
+	}
+	notifier, err := notify.New(cfg, store)
+	if err != nil {
+		return nil, err
+	}
+	svc := attendance.NewService(cfg, store, clientOpts...)
+	coordOpts := attendance.CoordinatorOptions{Timeout: runTimeout}
+	if cfg.RunLock {
+		locker, ok := store.(storage.Locker)
+		if !ok {
+			return nil, errors.New("store does not support RUN_LOCK")
+		}
+		coordOpts.Lock = locker
+	}
+	return &Function{
+		cfg:   cfg,
+		svc:   svc,
+		coord: attendance.NewCoordinator(svc, notifier, coordOpts),
+		tracer: tracing.Setup(tracing.Options{
+			Endpoint:    cfg.TracingEndpoint,
+			Headers:     cfg.TracingHeaders,
+			ServiceName: cfg.ServiceName,
+		}),
+	}, nil
+}
+
+// Shutdown stops the tracer, exporting the spans still pending.
+func (f *Function) Shutdown() {
+	if f.tracer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+	defer cancel()
+	if err := f.tracer.Shutdown(ctx); err != nil {
+		slog.Warn("导出链路追踪数据失败", "err", err)
+	}
+}
+
+// Handle runs req and returns the Response, which records err as well when
+// the run failed.
+func (f *Function) Handle(ctx context.Context, req Request) (Response, error) {
+	resp, err := f.Invoke(ctx, req)
+	if err != nil {
+		resp.Result = "failed"
+		resp.Error = err.Error()
+	}
+	return resp, err
+}
+
+// Invoke runs the attendance selected by req and waits for it, joining the
+// run in flight when it was started with the same options. Notifications
+// are pushed unless it is a dry run, which like in the CLI has no side
+// effects and runs on its own.
+func (f *Function) Invoke(ctx context.Context, req Request) (Response, error) {
+	opts := attendance.RunOptions{Accounts: req.Accounts, Games: req.Games, DryRun: req.DryRun, Force: req.Force}
+	if _, err := attendance.SelectAccounts(f.cfg, opts.Accounts); err != nil {
+		return Response{}, InvalidRequest(err)
+	}
+	// The instance may be frozen as soon as the invocation returns.
+	defer f.flush(ctx)
+
+	var out attendance.Outcome
+	if opts.DryRun {
+		out.RunID = attendance.NewRunID()
+		out.Result, out.Err = f.svc.RunWith(logging.With(ctx, "run_id", out.RunID), nil, opts)
+		if out.Result.Report != nil {
+			out.Result.Report.RunID = out.RunID
+		}
+	} else {
+		// The outcome, report included, is shared by the callers joining
+		// the run and must not be modified.
+		out = f.coord.RunWith(ctx, opts)
+	}
+
+	resp := Response{Result: out.Result.Result, RunID: out.RunID, DryRun: opts.DryRun}
+	if out.Result.Report != nil {
+		resp.Stats = &out.Result.Stats
+		resp.Report = out.Result.Report
+	}
+	if out.NotifyErr != nil {
+		resp.NotifyError = out.NotifyErr.Error()
+	}
+	if out.Err != nil {
+		return resp, out.Err
+	}
+	if out.NotifyErr != nil && f.cfg.NotificationStrict {
+		return resp, out.NotifyErr
+	}
+	return resp, nil
+}
+
+// flush exports the spans ended so far.
+func (f *Function) flush(ctx context.Context) {
+	if f.tracer == nil {
+		return
+	}
+	flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), traceFlushTimeout)
+	defer cancel()
+	if err := f.tracer.ForceFlush(flushCtx); err != nil {
+		slog.Warn("导出链路追踪数据失败", "err", err)
+	}
+}
@@ -0,0 +1,61 @@
+package cloudfn_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"skland-daily-attendance-go/internal/attendance"
+	"skland-daily-attendance-go/internal/cloudfn"
+	"skland-daily-attendance-go/internal/cloudfn/cloudfntest"
+)
+
+func TestInvokeConcurrent(t *testing.T) {
+	sk, fn := cloudfntest.New(t)
+	sk.SetDelay(50 * time.Millisecond)
+
+	// Concurrent requests of a warm instance share the run in flight.
+	const n = 4
+	ids := make([]string, n)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := fn.Invoke(context.Background(), cloudfn.Request{})
+			if err != nil {
+				t.Errorf("invoke: %v", err)
+			}
+			ids[i] = resp.RunID
+		}(i)
+	}
+	// Let the run start before asking for one with other options.
+	time.Sleep(20 * time.Millisecond)
+	_, conflict := fn.Invoke(context.Background(), cloudfn.Request{Games: []string{"arknights"}})
+	wg.Wait()
+
+	for _, id := range ids[1:] {
+		if id != ids[0] {
+			t.Errorf("run IDs = %v, want a single run", ids)
+			break
+		}
+	}
+	if claims := sk.Claims(); len(claims) != 1 {
+		t.Errorf("claims = %v, want one", claims)
+	}
+	if !errors.Is(conflict, attendance.ErrRunInProgress) || cloudfn.StatusCode(conflict) != http.StatusConflict {
+		t.Errorf("run with other options: err = %v, want a conflict", conflict)
+	}
+
+	// The store outlives invocations: the account is now attended.
+	resp, err := fn.Invoke(context.Background(), cloudfn.Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Stats.Accounts.Skipped != 1 {
+		t.Errorf("stats = %+v, want the attended account skipped", resp.Stats.Accounts)
+	}
+}
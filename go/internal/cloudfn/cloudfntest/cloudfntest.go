@@ -0,0 +1,32 @@
+// Package cloudfntest builds cloud functions for tests, running against a
+// fake Skland API.
+package cloudfntest
+
+import (
+	"testing"
+
+	"skland-daily-attendance-go/internal/cloudfn"
+	"skland-daily-attendance-go/internal/config"
+	"skland-daily-attendance-go/internal/skland/sklandtest"
+)
+
+// New returns a function configured from the environment with the single
+// account tok-a, bound to the Arknights character a1, and the fake API it
+// runs against. Notifications are disabled.
+func New(t *testing.T) (*sklandtest.Server, *cloudfn.Function) {
+	t.Helper()
+	sk := sklandtest.New(t)
+	sk.AddAccount("tok-a", sklandtest.Arknights("a1", "阿米娅"))
+	t.Setenv("TOKENS", "tok-a")
+	t.Setenv("NOTIFICATION_URLS", "")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn, err := cloudfn.New(cfg, sk.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fn.Shutdown)
+	return sk, fn
+}
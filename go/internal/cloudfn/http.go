@@ -0,0 +1,151 @@
+package cloudfn
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"skland-daily-attendance-go/internal/config"
+	"skland-daily-attendance-go/internal/server"
+)
+
+// maxBodyBytes bounds the request body, which only ever holds a Request.
+const maxBodyBytes = 64 << 10
+
+// Handler serves invocations over plain HTTP, as forwarded by the Aliyun FC
+// HTTP trigger and to Tencent SCF web functions. Options are read by
+// HTTPRequest and the Response is written as JSON with the StatusCode of
+// the run. Function URLs are public, so requests are checked by auth, which
+// admits everyone when it has no credentials. Only POST triggers a run, so
+// that link previews and crawlers fetching the URL never start one.
+func (f *Function) Handler(auth *server.Authenticator) http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			server.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var resp Response
+		req, err := HTTPRequest(body, r.URL.Query().Get)
+		if err == nil {
+			resp, err = f.Handle(r.Context(), req)
+		} else {
+			resp = Response{Result: "failed", Error: err.Error()}
+		}
+		server.WriteJSON(w, StatusCode(err), resp)
+	})
+	return server.LimitBody(auth.Wrap(server.Methods{http.MethodPost: h}), maxBodyBytes)
+}
+
+// NewAuthenticator creates the authenticator of HTTP triggers and API
+// Gateway events from the HTTP credentials in cfg, warning when there are
+// none.
+func NewAuthenticator(cfg *config.Config) *server.Authenticator {
+	auth := server.NewAuthenticator(cfg.HTTPAuthTokens, cfg.HTTPHMACSecret)
+	if !auth.Enabled() {
+		slog.Warn("未设置 HTTP_AUTH_TOKENS 或 HTTP_HMAC_SECRET，任何人都可以通过 HTTP 触发器或 API 网关触发签到")
+	}
+	return auth
+}
+
+// EventRequest is an HTTP request delivered as an event, such as an API
+// Gateway request.
+type EventRequest struct {
+	Method string
+	// Path is the path the function received, without the query.
+	Path     string
+	RawQuery string
+	Header   http.Header
+	Body     string
+	// Base64Encoded is set when Body is base64 encoded.
+	Base64Encoded bool
+	// SourceIP is the address of the client, used to limit failed
+	// authentications.
+	SourceIP string
+}
+
+// HTTP returns e as an *http.Request, to be served by ServeEvent.
+func (e EventRequest) HTTP(ctx context.Context) (*http.Request, error) {
+	body := []byte(e.Body)
+	if e.Base64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(e.Body); err != nil {
+			return nil, InvalidRequest(err)
+		}
+	}
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	u := &url.URL{Scheme: "http", Host: "function", Path: path, RawQuery: e.RawQuery}
+	r, err := http.NewRequestWithContext(ctx, e.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, InvalidRequest(err)
+	}
+	if e.Header != nil {
+		r.Header = e.Header
+	}
+	r.RemoteAddr = e.SourceIP
+	return r, nil
+}
+
+// EventResponse is the response to an HTTP request delivered as an event,
+// in the shape of API Gateway proxy responses: every header has a single
+// value, several values being joined by commas.
+type EventResponse struct {
+	StatusCode int
+	Headers    map[string]string
+	Body       string
+}
+
+// ServeEvent serves r, an HTTP request delivered as an event such as an API
+// Gateway request, with h and returns the response.
+func ServeEvent(h http.Handler, r *http.Request) EventResponse {
+	w := &eventResponse{header: make(http.Header)}
+	h.ServeHTTP(w, r)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	headers := make(map[string]string, len(w.header))
+	for k, vs := range w.header {
+		headers[k] = strings.Join(vs, ", ")
+	}
+	return EventResponse{StatusCode: w.status, Headers: headers, Body: w.body.String()}
+}
+
+// EventError returns the response to an event whose HTTP request could not
+// be read, answered like Handler answers an invalid request.
+func EventError(err error) EventResponse {
+	body, _ := json.Marshal(Response{Result: "failed", Error: err.Error()})
+	return EventResponse{
+		StatusCode: StatusCode(err),
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(body),
+	}
+}
+
+// eventResponse buffers the response of ServeEvent.
+type eventResponse struct {
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *eventResponse) Header() http.Header { return w.header }
+
+func (w *eventResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *eventResponse) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
@@ -0,0 +1,131 @@
+package cloudfn_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"skland-daily-attendance-go/internal/cloudfn"
+	"skland-daily-attendance-go/internal/cloudfn/cloudfntest"
+	"skland-daily-attendance-go/internal/server"
+)
+
+// serveEvent serves e with h as the runtimes serve API Gateway requests.
+func serveEvent(t *testing.T, h http.Handler, e cloudfn.EventRequest) (cloudfn.EventResponse, cloudfn.Response) {
+	t.Helper()
+	var out cloudfn.EventResponse
+	if r, err := e.HTTP(context.Background()); err != nil {
+		out = cloudfn.EventError(err)
+	} else {
+		out = cloudfn.ServeEvent(h, r)
+	}
+	var resp cloudfn.Response
+	_ = json.Unmarshal([]byte(out.Body), &resp)
+	return out, resp
+}
+
+func TestHandler(t *testing.T) {
+	sk, fn := cloudfntest.New(t)
+	h := fn.Handler(server.NewAuthenticator([]string{"secret"}, "hmac-secret"))
+
+	bearer := http.Header{"Authorization": {"Bearer secret"}}
+	signed := func(method, path, body string) http.Header {
+		ts := time.Now().Unix()
+		return http.Header{
+			"X-Skland-Timestamp": {strconv.FormatInt(ts, 10)},
+			"X-Skland-Signature": {server.Sign("hmac-secret", ts, method, path, []byte(body))},
+		}
+	}
+	replayed := signed(http.MethodPost, "/attendance", `{"dryRun":true}`)
+
+	tests := []struct {
+		name       string
+		event      cloudfn.EventRequest
+		wantStatus int
+		wantResult string
+	}{
+		{name: "get", event: cloudfn.EventRequest{Method: http.MethodGet, Header: bearer}, wantStatus: http.StatusMethodNotAllowed},
+		{name: "head", event: cloudfn.EventRequest{Method: http.MethodHead, Header: bearer}, wantStatus: http.StatusMethodNotAllowed},
+		{name: "without credentials", event: cloudfn.EventRequest{Method: http.MethodPost}, wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", event: cloudfn.EventRequest{Method: http.MethodPost, Header: http.Header{"Authorization": {"Bearer nope"}}}, wantStatus: http.StatusUnauthorized},
+		{name: "signed", event: cloudfn.EventRequest{Method: http.MethodPost, Path: "/attendance", Header: replayed, Body: `{"dryRun":true}`}, wantStatus: http.StatusOK, wantResult: "success"},
+		{name: "replayed signature", event: cloudfn.EventRequest{Method: http.MethodPost, Path: "/attendance", Header: replayed, Body: `{"dryRun":true}`}, wantStatus: http.StatusUnauthorized},
+		{name: "signature of another path", event: cloudfn.EventRequest{Method: http.MethodPost, Path: "/attendance", Header: signed(http.MethodPost, "/other", "")}, wantStatus: http.StatusUnauthorized},
+		{name: "invalid option", event: cloudfn.EventRequest{Method: http.MethodPost, RawQuery: "dryRun=maybe", Header: bearer}, wantStatus: http.StatusBadRequest, wantResult: "failed"},
+		{name: "unknown account", event: cloudfn.EventRequest{Method: http.MethodPost, Header: bearer, Body: `{"accounts":["nobody"]}`}, wantStatus: http.StatusBadRequest, wantResult: "failed"},
+		{name: "malformed base64", event: cloudfn.EventRequest{Method: http.MethodPost, Header: bearer, Body: "%%", Base64Encoded: true}, wantStatus: http.StatusBadRequest, wantResult: "failed"},
+		{name: "dry run by query", event: cloudfn.EventRequest{Method: http.MethodPost, RawQuery: "dryRun=true", Header: bearer}, wantStatus: http.StatusOK, wantResult: "success"},
+		{
+			name: "run with base64 body",
+			event: cloudfn.EventRequest{
+				Method: http.MethodPost, Header: bearer, Base64Encoded: true,
+				Body: base64.StdEncoding.EncodeToString([]byte(`{"games":["arknights"]}`)),
+			},
+			wantStatus: http.StatusOK, wantResult: "success",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Distinct clients, so that failures do not add up to the limit.
+			tt.event.SourceIP = "203.0.113." + strconv.Itoa(i)
+			out, resp := serveEvent(t, h, tt.event)
+			if out.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", out.StatusCode, tt.wantStatus, out.Body)
+			}
+			switch out.StatusCode {
+			case http.StatusMethodNotAllowed:
+				if out.Headers["Allow"] != http.MethodPost {
+					t.Errorf("Allow = %q, want POST", out.Headers["Allow"])
+				}
+			case http.StatusUnauthorized:
+				if out.Headers["Www-Authenticate"] == "" {
+					t.Errorf("401 without WWW-Authenticate: %v", out.Headers)
+				}
+			}
+			if tt.wantResult == "" {
+				return
+			}
+			if ct := out.Headers["Content-Type"]; ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if resp.Result != tt.wantResult {
+				t.Errorf("result = %q, want %q (%s)", resp.Result, tt.wantResult, resp.Error)
+			}
+		})
+	}
+	if claims := sk.Claims(); len(claims) != 1 {
+		t.Errorf("claims = %v, want only the run to claim", claims)
+	}
+}
+
+func TestHandlerFailureLimit(t *testing.T) {
+	_, fn := cloudfntest.New(t)
+	h := fn.Handler(server.NewAuthenticator([]string{"secret"}, ""))
+	var last int
+	for i := 0; i < 12; i++ {
+		out, _ := serveEvent(t, h, cloudfn.EventRequest{
+			Method:   http.MethodPost,
+			Header:   http.Header{"Authorization": {"Bearer guess" + strconv.Itoa(i)}},
+			SourceIP: "203.0.113.7",
+		})
+		last = out.StatusCode
+	}
+	if last != http.StatusTooManyRequests {
+		t.Errorf("status after repeated failures = %d, want 429", last)
+	}
+
+	// Other clients are not limited.
+	out, _ := serveEvent(t, h, cloudfn.EventRequest{
+		Method:   http.MethodPost,
+		RawQuery: "dryRun=true",
+		Header:   http.Header{"Authorization": {"Bearer secret"}},
+		SourceIP: "203.0.113.8",
+	})
+	if out.StatusCode != http.StatusOK {
+		t.Errorf("status of another client = %d, want 200: %s", out.StatusCode, out.Body)
+	}
+}
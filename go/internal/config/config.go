@@ -1,33 +1,187 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
+
+	// Embed the timezone database: minimal images such as alpine ship without it.
+	_ "time/tzdata"
 )
 
+// AccountNotification routes the notifications of a single account.
+type AccountNotification struct {
+	URLs []string
+	// Replace sends the account only to URLs instead of also to the
+	// global NotificationURLs.
+	Replace bool
+}
+
 // Config holds runtime configuration loaded from environment variables.
 type Config struct {
 	// Comma separated tokens
 	Tokens []string
+	// Comma separated account names, matched to Tokens by position
+	AccountNames []string
 	// Comma separated notification URLs
 	NotificationURLs []string
 	// MaxRetries for each character attendance
 	MaxRetries int
+	// AccountNotifications holds per-account targets, keyed by the 1-based
+	// account number
+	AccountNotifications map[int]AccountNotification
+	// AdminNotificationURLs receive the full report of every account
+	AdminNotificationURLs []string
+	// NotificationStrict treats a failed notification delivery as a failed run
+	NotificationStrict bool
+	// NotificationPolicy is the default delivery policy: always, on-failure or on-change
+	NotificationPolicy string
+	// NotificationMinLevel drops messages below this level: debug, info, warn or error
+	NotificationMinLevel string
+	// NotificationQuietHours defers delivery inside a daily window, e.g. 23:00-07:00
+	NotificationQuietHours string
+	// Location is the timezone used for dates, quiet hours and schedules
+	Location *time.Location
+	// NotificationTemplates maps a provider name (e.g. "discord") to a
+	// template file path or inline template; the empty key applies to all.
+	NotificationTemplates map[string]string
+	// Schedule is the cron expression of daemon mode, in Location
+	Schedule string
+	// ScheduleJitter delays each scheduled run by a random duration up to this
+	ScheduleJitter time.Duration
+	// StorePath is the JSON file persisting state between runs; empty keeps
+	// state in memory
+	StorePath string
+	// HTTPAuthTokens are the bearer tokens accepted by the HTTP mode
+	HTTPAuthTokens []string
+	// HTTPHMACSecret verifies signed requests in HTTP mode
+	HTTPHMACSecret string
+	// HTTPRateLimit is the number of requests per minute allowed per client
+	// in HTTP mode; 0 disables the limit
+	HTTPRateLimit int
+	// RunLock guards runs with a lock in the store, so that replicas sharing
+	// the store never run at the same time
+	RunLock bool
+	// ReadyCheckSkland makes the readiness probe resolve the Skland API host
+	ReadyCheckSkland bool
+	// LogLevel is the minimum log level: debug, info, warn or error
+	LogLevel string
+	// LogFormat is the log output format: text or json
+	LogFormat string
+	// TracingEndpoint is the OTLP/HTTP traces URL; empty disables tracing
+	TracingEndpoint string
+	// TracingHeaders are sent with every trace export
+	TracingHeaders map[string]string
+	// ServiceName is reported as the service.name of exported traces
+	ServiceName string
+	// QingLong configures the QingLong panel integration
+	QingLong QingLong
+}
+
+// QingLong holds the OpenAPI settings of a QingLong panel.
+type QingLong struct {
+	// URL is the panel address; it defaults to the local panel when
+	// running inside QingLong
+	URL string
+	// ClientID and ClientSecret are the credentials of a panel application
+	ClientID     string
+	ClientSecret string
+	// Notify sends the report through the panel's notification settings
+	Notify bool
+}
+
+// Enabled reports whether the panel OpenAPI can be called.
+func (q QingLong) Enabled() bool {
+	return q.URL != "" && q.ClientID != "" && q.ClientSecret != ""
 }
 
 const (
 	envTokens           = "TOKENS"
 	envNotificationURLs = "NOTIFICATION_URLS"
 	envMaxRetries       = "MAX_RETRIES"
+	envAccountNames     = "ACCOUNT_NAMES"
+
+	envNotificationTemplate  = "NOTIFICATION_TEMPLATE"
+	envNotificationStrict    = "NOTIFICATION_STRICT"
+	envNotificationPolicy    = "NOTIFICATION_POLICY"
+	envNotificationMinLevel  = "NOTIFICATION_MIN_LEVEL"
+	envNotificationQuiet     = "NOTIFICATION_QUIET_HOURS"
+	envTimezone              = "TIMEZONE"
+	envAdminNotificationURLs = "ADMIN_NOTIFICATION_URLS"
+	envSchedule              = "SCHEDULE"
+	envScheduleJitter        = "SCHEDULE_JITTER"
+	envStorePath             = "STORE_PATH"
+	envHTTPAuthTokens        = "HTTP_AUTH_TOKENS"
+	envHTTPHMACSecret        = "HTTP_HMAC_SECRET"
+	envHTTPRateLimit         = "HTTP_RATE_LIMIT"
+	envRunLock               = "RUN_LOCK"
+	envReadyCheckSkland      = "READY_CHECK_SKLAND"
+	envLogLevel              = "LOG_LEVEL"
+	envLogFormat             = "LOG_FORMAT"
+	envOTLPEndpoint          = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	envOTLPTracesEndpoint    = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	envOTLPHeaders           = "OTEL_EXPORTER_OTLP_HEADERS"
+	envServiceName           = "OTEL_SERVICE_NAME"
+	envSklandTokens          = "SKLAND_TOKENS"
+	envQLDir                 = "QL_DIR"
+	envQLURL                 = "QL_URL"
+	envQLClientID            = "QL_CLIENT_ID"
+	envQLClientSecret        = "QL_CLIENT_SECRET"
+	envQLNotify              = "QL_NOTIFY"
+
+	defaultTimezone = "Asia/Shanghai"
+	defaultSchedule = "0 8 * * *"
+	defaultJitter   = 10 * time.Minute
+
+	defaultHTTPRateLimit = 6
+	defaultServiceName   = "skland-attendance"
+	// defaultQLURL is the panel as seen from scripts running inside it.
+	defaultQLURL = "http://127.0.0.1:5700"
 )
 
 // Load reads configuration from environment variables.
 func Load() (*Config, error) {
 	cfg := &Config{
 		Tokens:           splitAndTrim(os.Getenv(envTokens)),
+		AccountNames:     splitPositional(os.Getenv(envAccountNames)),
 		NotificationURLs: splitAndTrim(os.Getenv(envNotificationURLs)),
 		MaxRetries:       3,
+		Schedule:         defaultSchedule,
+		ScheduleJitter:   defaultJitter,
+		StorePath:        os.Getenv(envStorePath),
+		HTTPAuthTokens:   splitAndTrim(os.Getenv(envHTTPAuthTokens)),
+		HTTPHMACSecret:   os.Getenv(envHTTPHMACSecret),
+		HTTPRateLimit:    defaultHTTPRateLimit,
+		LogLevel:         os.Getenv(envLogLevel),
+		LogFormat:        os.Getenv(envLogFormat),
+		TracingEndpoint:  tracesEndpoint(os.Getenv(envOTLPEndpoint), os.Getenv(envOTLPTracesEndpoint)),
+		TracingHeaders:   parseHeaders(os.Getenv(envOTLPHeaders)),
+		ServiceName:      defaultServiceName,
+	}
+	if v := os.Getenv(envServiceName); v != "" {
+		cfg.ServiceName = v
+	}
+	// QingLong scripts conventionally read SKLAND_TOKENS separated by & or
+	// newlines.
+	if len(cfg.Tokens) == 0 {
+		cfg.Tokens = splitQingLong(os.Getenv(envSklandTokens))
+	}
+	cfg.QingLong = QingLong{
+		URL:          os.Getenv(envQLURL),
+		ClientID:     os.Getenv(envQLClientID),
+		ClientSecret: os.Getenv(envQLClientSecret),
+	}
+	if cfg.QingLong.URL == "" && os.Getenv(envQLDir) != "" {
+		cfg.QingLong.URL = defaultQLURL
+	}
+	if v := os.Getenv(envQLNotify); v != "" {
+		cfg.QingLong.Notify, _ = strconv.ParseBool(v)
+	}
+	if cfg.QingLong.Notify && !cfg.QingLong.Enabled() {
+		return nil, fmt.Errorf("%s requires %s, %s and %s", envQLNotify, envQLURL, envQLClientID, envQLClientSecret)
 	}
 
 	if v := os.Getenv(envMaxRetries); v != "" {
@@ -36,9 +190,163 @@ func Load() (*Config, error) {
 		}
 	}
 
+	if v := os.Getenv(envHTTPRateLimit); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid %s %q", envHTTPRateLimit, v)
+		}
+		cfg.HTTPRateLimit = n
+	}
+
+	if v := os.Getenv(envNotificationStrict); v != "" {
+		cfg.NotificationStrict, _ = strconv.ParseBool(v)
+	}
+	if v := os.Getenv(envRunLock); v != "" {
+		cfg.RunLock, _ = strconv.ParseBool(v)
+	}
+	if v := os.Getenv(envReadyCheckSkland); v != "" {
+		cfg.ReadyCheckSkland, _ = strconv.ParseBool(v)
+	}
+	cfg.NotificationTemplates = loadTemplates()
+	cfg.AdminNotificationURLs = splitAndTrim(os.Getenv(envAdminNotificationURLs))
+	accountNotifications, err := loadAccountNotifications(len(cfg.Tokens))
+	if err != nil {
+		return nil, err
+	}
+	cfg.AccountNotifications = accountNotifications
+	cfg.NotificationPolicy = os.Getenv(envNotificationPolicy)
+	cfg.NotificationMinLevel = os.Getenv(envNotificationMinLevel)
+	cfg.NotificationQuietHours = os.Getenv(envNotificationQuiet)
+
+	tz := os.Getenv(envTimezone)
+	if tz == "" {
+		tz = defaultTimezone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", envTimezone, err)
+	}
+	cfg.Location = loc
+
+	if v := os.Getenv(envSchedule); v != "" {
+		cfg.Schedule = v
+	}
+	if v := os.Getenv(envScheduleJitter); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid %s %q", envScheduleJitter, v)
+		}
+		cfg.ScheduleJitter = d
+	}
+
 	return cfg, nil
 }
 
+// AccountLabel returns the configured name of the account at idx (0-based),
+// falling back to its 1-based position.
+func (c *Config) AccountLabel(idx int) string {
+	if idx < len(c.AccountNames) {
+		if name := c.AccountNames[idx]; name != "" {
+			return name
+		}
+	}
+	return "账号" + strconv.Itoa(idx+1)
+}
+
+// Secrets returns every credential in the configuration, to be redacted
+// from logs: account tokens, HTTP credentials, trace export headers and the
+// passwords embedded in notification URLs.
+func (c *Config) Secrets() []string {
+	secrets := append([]string(nil), c.Tokens...)
+	secrets = append(secrets, c.HTTPAuthTokens...)
+	if c.HTTPHMACSecret != "" {
+		secrets = append(secrets, c.HTTPHMACSecret)
+	}
+	if c.QingLong.ClientSecret != "" {
+		secrets = append(secrets, c.QingLong.ClientSecret)
+	}
+	for _, v := range c.TracingHeaders {
+		if v != "" {
+			secrets = append(secrets, v)
+		}
+	}
+	urls := append(append([]string(nil), c.NotificationURLs...), c.AdminNotificationURLs...)
+	for _, an := range c.AccountNotifications {
+		urls = append(urls, an.URLs...)
+	}
+	for _, raw := range urls {
+		if u, err := url.Parse(raw); err == nil && u.User != nil {
+			if p, ok := u.User.Password(); ok && p != "" {
+				secrets = append(secrets, p)
+			}
+		}
+	}
+	return secrets
+}
+
+// splitQingLong splits a QingLong style list separated by "&", newlines or
+// commas.
+func splitQingLong(v string) []string {
+	var out []string
+	for _, s := range strings.FieldsFunc(v, func(r rune) bool {
+		return r == '&' || r == '\n' || r == '\r' || r == ','
+	}) {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// tracesEndpoint derives the OTLP traces URL: the signal specific endpoint
+// is used as is, the base endpoint gets /v1/traces appended.
+func tracesEndpoint(base, traces string) string {
+	if traces != "" {
+		return traces
+	}
+	if base == "" {
+		return ""
+	}
+	return strings.TrimSuffix(base, "/") + "/v1/traces"
+}
+
+// parseHeaders parses a comma separated key=value list whose values may be
+// percent-encoded, as in OTEL_EXPORTER_OTLP_HEADERS.
+func parseHeaders(v string) map[string]string {
+	headers := make(map[string]string)
+	for _, part := range strings.Split(v, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if u, err := url.QueryUnescape(value); err == nil {
+			value = u
+		}
+		headers[key] = value
+	}
+	return headers
+}
+
+// loadTemplates collects NOTIFICATION_TEMPLATE and the provider specific
+// NOTIFICATION_TEMPLATE_<PROVIDER> variables.
+func loadTemplates() map[string]string {
+	templates := make(map[string]string)
+	for _, kv := range os.Environ() {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || value == "" {
+			continue
+		}
+		if key == envNotificationTemplate {
+			templates[""] = value
+		} else if name, ok := strings.CutPrefix(key, envNotificationTemplate+"_"); ok && name != "" {
+			templates[strings.ToLower(name)] = value
+		}
+	}
+	return templates
+}
+
 func splitAndTrim(v string) []string {
 	if v == "" {
 		return nil
@@ -54,3 +362,38 @@ func splitAndTrim(v string) []string {
 	return out
 }
 
+// loadAccountNotifications reads ACCOUNT_<N>_NOTIFICATION_URLS and
+// ACCOUNT_<N>_NOTIFICATION_MODE (append | replace) for each account.
+func loadAccountNotifications(accounts int) (map[int]AccountNotification, error) {
+	out := make(map[int]AccountNotification)
+	for n := 1; n <= accounts; n++ {
+		prefix := "ACCOUNT_" + strconv.Itoa(n) + "_NOTIFICATION_"
+		urls := splitAndTrim(os.Getenv(prefix + "URLS"))
+		if len(urls) == 0 {
+			continue
+		}
+		an := AccountNotification{URLs: urls}
+		switch mode := os.Getenv(prefix + "MODE"); mode {
+		case "", "append":
+		case "replace":
+			an.Replace = true
+		default:
+			return nil, fmt.Errorf("invalid %sMODE %q, want append or replace", prefix, mode)
+		}
+		out[n] = an
+	}
+	return out, nil
+}
+
+// splitPositional splits a comma separated list keeping empty entries, so
+// that values stay aligned with Tokens by position.
+func splitPositional(v string) []string {
+	if v == "" {
+		return nil
+	}
+	parts := strings.Split(v, ",")
+	for i, p := range parts {
+		parts[i] = strings.TrimSpace(p)
+	}
+	return parts
+}
@@ -0,0 +1,177 @@
+// Package events streams the progress of runs to live subscribers, with
+// replay of the events already emitted for late subscribers.
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Type identifies the kind of an event.
+type Type string
+
+const (
+	RunStarted      Type = "run.started"
+	AccountStarted  Type = "account.started"
+	AccountError    Type = "account.error"
+	Character       Type = "character"
+	AccountFinished Type = "account.finished"
+	RunFinished     Type = "run.finished"
+)
+
+// Event is a single step of a run.
+type Event struct {
+	// Seq numbers the events of a run from 1.
+	Seq          int       `json:"seq"`
+	Type         Type      `json:"type"`
+	RunID        string    `json:"runId"`
+	Time         time.Time `json:"time"`
+	Account      int       `json:"account,omitempty"`
+	AccountLabel string    `json:"accountLabel,omitempty"`
+	// Total is the number of accounts, set on run.started.
+	Total     int    `json:"total,omitempty"`
+	GameName  string `json:"gameName,omitempty"`
+	Character string `json:"character,omitempty"`
+	// Result is "success", "already_attended", "skipped" or "failed" for
+	// characters and accounts, and the run result on run.finished.
+	Result  string   `json:"result,omitempty"`
+	Text    string   `json:"text,omitempty"`
+	Rewards []string `json:"rewards,omitempty"`
+}
+
+// Publisher receives the events of a single run.
+type Publisher func(Event)
+
+type publisherKey struct{}
+
+// WithPublisher returns a context whose events are sent to p.
+func WithPublisher(ctx context.Context, p Publisher) context.Context {
+	return context.WithValue(ctx, publisherKey{}, p)
+}
+
+// Publish sends e to the publisher of ctx, if any.
+func Publish(ctx context.Context, e Event) {
+	if p, ok := ctx.Value(publisherKey{}).(Publisher); ok && p != nil {
+		p(e)
+	}
+}
+
+// subscriberBuffer is the number of events a subscriber may fall behind
+// before it is dropped; it can reconnect and resume from the replay.
+const subscriberBuffer = 64
+
+// Bus keeps the event stream of recent runs.
+type Bus struct {
+	retain int
+
+	mu      sync.Mutex
+	streams map[string]*stream
+	closed  []string
+}
+
+type stream struct {
+	events []Event
+	subs   map[chan Event]struct{}
+	done   bool
+}
+
+// NewBus creates a bus keeping the streams of the last retain finished runs.
+func NewBus(retain int) *Bus {
+	return &Bus{
+		retain:  retain,
+		streams: make(map[string]*stream),
+	}
+}
+
+// Publisher returns the publisher of the run with the given ID, opening its
+// stream.
+func (b *Bus) Publisher(runID string) Publisher {
+	b.mu.Lock()
+	b.open(runID)
+	b.mu.Unlock()
+	return func(e Event) { b.publish(runID, e) }
+}
+
+func (b *Bus) open(runID string) *stream {
+	s, ok := b.streams[runID]
+	if !ok {
+		s = &stream{subs: make(map[chan Event]struct{})}
+		b.streams[runID] = s
+	}
+	return s
+}
+
+func (b *Bus) publish(runID string, e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.open(runID)
+	if s.done {
+		return
+	}
+	e.Seq = len(s.events) + 1
+	e.RunID = runID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	s.events = append(s.events, e)
+	for ch := range s.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close ends the stream of a run, closing its subscriptions. The stream is
+// kept for replay until more than retain runs have finished after it.
+func (b *Bus) Close(runID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.streams[runID]
+	if !ok || s.done {
+		return
+	}
+	s.done = true
+	for ch := range s.subs {
+		close(ch)
+	}
+	s.subs = nil
+
+	b.closed = append(b.closed, runID)
+	for len(b.closed) > b.retain {
+		delete(b.streams, b.closed[0])
+		b.closed = b.closed[1:]
+	}
+}
+
+// Subscribe returns the events of a run emitted so far and a channel of the
+// following ones, which is closed when the run ends or the subscriber falls
+// too far behind. It returns ok false if the run is unknown. Call cancel to
+// stop receiving.
+func (b *Bus) Subscribe(runID string) (replay []Event, live <-chan Event, cancel func(), ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.streams[runID]
+	if !ok {
+		return nil, nil, nil, false
+	}
+	replay = append([]Event(nil), s.events...)
+	ch := make(chan Event, subscriberBuffer)
+	if s.done {
+		close(ch)
+		return replay, ch, func() {}, true
+	}
+	s.subs[ch] = struct{}{}
+	cancel = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := s.subs[ch]; ok {
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+	return replay, ch, cancel, true
+}
@@ -0,0 +1,127 @@
+// Package ghactions speaks the GitHub Actions workflow command protocol:
+// log annotations and masks on stdout, and the step summary and outputs
+// files named by the runner's environment.
+package ghactions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Runner is a GitHub Actions step environment.
+type Runner struct {
+	// Out receives workflow commands, normally stdout.
+	Out io.Writer
+	// SummaryPath is the file of $GITHUB_STEP_SUMMARY; empty disables the
+	// summary.
+	SummaryPath string
+	// OutputPath is the file of $GITHUB_OUTPUT; empty disables outputs.
+	OutputPath string
+}
+
+// Detect returns the runner of the current process, or nil outside GitHub
+// Actions.
+func Detect() *Runner {
+	if os.Getenv("GITHUB_ACTIONS") != "true" {
+		return nil
+	}
+	return &Runner{
+		Out:         os.Stdout,
+		SummaryPath: os.Getenv("GITHUB_STEP_SUMMARY"),
+		OutputPath:  os.Getenv("GITHUB_OUTPUT"),
+	}
+}
+
+// Mask hides each non-empty value from the rest of the job's log.
+func (r *Runner) Mask(values ...string) {
+	for _, v := range values {
+		// A mask applies to a single line; mask multi-line values per line.
+		for _, line := range strings.Split(v, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				fmt.Fprintf(r.Out, "::add-mask::%s\n", escapeData(line))
+			}
+		}
+	}
+}
+
+// Error emits an error annotation with an optional title.
+func (r *Runner) Error(title, msg string) {
+	r.annotate("error", title, msg)
+}
+
+// Warning emits a warning annotation with an optional title.
+func (r *Runner) Warning(title, msg string) {
+	r.annotate("warning", title, msg)
+}
+
+func (r *Runner) annotate(level, title, msg string) {
+	if title != "" {
+		fmt.Fprintf(r.Out, "::%s title=%s::%s\n", level, escapeProperty(title), escapeData(msg))
+		return
+	}
+	fmt.Fprintf(r.Out, "::%s::%s\n", level, escapeData(msg))
+}
+
+// AppendSummary appends Markdown to the step summary.
+func (r *Runner) AppendSummary(markdown string) error {
+	if r.SummaryPath == "" {
+		return nil
+	}
+	return appendFile(r.SummaryPath, markdown)
+}
+
+// SetOutputs sets step outputs, in key order.
+func (r *Runner) SetOutputs(outputs map[string]string) error {
+	if r.OutputPath == "" || len(outputs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(outputs))
+	for k := range outputs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		v := outputs[k]
+		if !strings.ContainsAny(v, "\r\n") {
+			fmt.Fprintf(&b, "%s=%s\n", k, v)
+			continue
+		}
+		delim := delimiter()
+		fmt.Fprintf(&b, "%s<<%s\n%s\n%s\n", k, delim, v, delim)
+	}
+	return appendFile(r.OutputPath, b.String())
+}
+
+func appendFile(path, s string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, s); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// delimiter returns a random heredoc delimiter that cannot appear in a
+// value by accident.
+func delimiter() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "ghadelimiter_" + hex.EncodeToString(b)
+}
+
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,94 @@
+// Package logging configures log/slog for the project: text or JSON output,
+// a configurable level, loggers carried in contexts, and redaction of
+// credentials so logs can be shipped safely.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Options configures New.
+type Options struct {
+	// Format is "text" (default) or "json".
+	Format string
+	// Level is the minimum level logged.
+	Level slog.Level
+	// Secrets are values redacted wherever they appear, e.g. account
+	// tokens and API keys.
+	Secrets []string
+}
+
+// New creates a logger writing to w that redacts credentials.
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	r := newRedactor(opts.Secrets)
+	hopts := &slog.HandlerOptions{
+		Level:       opts.Level,
+		ReplaceAttr: r.replaceAttr,
+	}
+	var h slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		h = slog.NewTextHandler(w, hopts)
+	case "json":
+		h = slog.NewJSONHandler(w, hopts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q, want text or json", opts.Format)
+	}
+	return slog.New(&redactingHandler{Handler: h, r: r}), nil
+}
+
+// ParseLevel parses debug, info, warn or error; empty means info.
+func ParseLevel(s string) (slog.Level, error) {
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, want debug, info, warn or error", s)
+	}
+	return l, nil
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a context carrying l.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger of ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// With returns a context whose logger has the given attributes added.
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
+
+// redactingHandler redacts log messages; attributes are redacted by the
+// ReplaceAttr hook of the wrapped handler.
+type redactingHandler struct {
+	slog.Handler
+	r *redactor
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, rec slog.Record) error {
+	rec.Message = h.r.redact(rec.Message)
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &redactingHandler{Handler: h.Handler.WithAttrs(attrs), r: h.r}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{Handler: h.Handler.WithGroup(name), r: h.r}
+}
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Redacted replaces sensitive values.
+const Redacted = "[REDACTED]"
+
+// minSecretLength keeps short values, which would match unrelated text,
+// from being registered as secrets.
+const minSecretLength = 6
+
+// sensitiveKeys are substrings of attribute keys whose values are always
+// redacted.
+var sensitiveKeys = []string{"token", "password", "passwd", "secret", "authorization", "signature", "credential", "cookie", "apikey", "api_key"}
+
+var (
+	// userinfoPattern matches credentials embedded in URLs.
+	userinfoPattern = regexp.MustCompile(`(://)[^/@\s]+@`)
+	// queryPattern matches sensitive query parameters.
+	queryPattern = regexp.MustCompile(`(?i)([?&](?:token|access_token|key|apikey|api_key|secret|password|pass|sign|signature|sendkey|cred)=)[^&\s"]*`)
+	// bearerPattern matches Authorization header values.
+	bearerPattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`)
+	// signaturePattern matches HMAC signatures.
+	signaturePattern = regexp.MustCompile(`(?i)\bsha256=[0-9a-f]+`)
+	// webhookPathPattern matches webhook URLs that carry their secret in
+	// the path (Discord, Slack, Teams).
+	webhookPathPattern = regexp.MustCompile(`(?i)(discord(?:app)?\.com/api/webhooks/\d+/|hooks\.slack\.com/services/|webhook\.office\.com/webhookb2/)[^\s"?]+`)
+)
+
+type redactor struct {
+	secrets []string
+}
+
+func newRedactor(secrets []string) *redactor {
+	r := &redactor{}
+	for _, s := range secrets {
+		if len(s) >= minSecretLength {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	// Longest first, so a secret containing another is replaced whole.
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+	return r
+}
+
+// redact removes credentials from s.
+func (r *redactor) redact(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	s = userinfoPattern.ReplaceAllString(s, "${1}"+Redacted+"@")
+	s = queryPattern.ReplaceAllString(s, "${1}"+Redacted)
+	s = bearerPattern.ReplaceAllString(s, "${1} "+Redacted)
+	s = signaturePattern.ReplaceAllString(s, "sha256="+Redacted)
+	s = webhookPathPattern.ReplaceAllString(s, "${1}"+Redacted)
+	return s
+}
+
+func (r *redactor) replaceAttr(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, k := range sensitiveKeys {
+		if strings.Contains(key, k) {
+			return slog.String(a.Key, Redacted)
+		}
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.redact(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, r.redact(v.Error()))
+		case []string:
+			out := make([]string, len(v))
+			for i, s := range v {
+				out[i] = r.redact(s)
+			}
+			return slog.Any(a.Key, out)
+		}
+	}
+	return a
+}
@@ -0,0 +1,33 @@
+package metrics
+
+// Default is the registry exposed at /metrics.
+var Default = NewRegistry()
+
+// Project metrics, registered in Default.
+var (
+	// Runs counts finished runs by status: success, failed or error.
+	Runs = Default.NewCounterVec("skland_runs_total",
+		"Attendance runs by final status.", "status")
+	// RunDuration observes how long runs take.
+	RunDuration = Default.NewHistogramVec("skland_run_duration_seconds",
+		"Duration of attendance runs.", []float64{1, 5, 10, 30, 60, 120, 300, 600})
+	// AccountResults counts account outcomes: success, skipped or failed.
+	AccountResults = Default.NewCounterVec("skland_account_results_total",
+		"Account outcomes of attendance runs.", "result")
+	// Characters counts character outcomes per game: succeeded,
+	// already_attended or failed, mirroring the per-game statistics.
+	Characters = Default.NewCounterVec("skland_character_results_total",
+		"Character attendance outcomes by game.", "game", "result")
+	// LastAccountSuccess is the Unix time of the last successful attendance
+	// of each account.
+	LastAccountSuccess = Default.NewGaugeVec("skland_account_last_success_timestamp_seconds",
+		"Unix time of the last successful attendance of an account.", "account")
+	// APIRequests observes Skland API call latencies by endpoint and status
+	// code, or "error" when no response was received.
+	APIRequests = Default.NewHistogramVec("skland_api_request_duration_seconds",
+		"Latency of Skland API calls.", nil, "endpoint", "status")
+	// NotificationDeliveries counts deliveries per provider by result:
+	// success, failure or deferred.
+	NotificationDeliveries = Default.NewCounterVec("skland_notification_deliveries_total",
+		"Notification deliveries by provider and result.", "provider", "result")
+)
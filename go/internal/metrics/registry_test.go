@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	runs := r.NewCounterVec("runs_total", "Runs by result.\nCounted once per run, see C:\\docs.", "result", "trigger")
+	last := r.NewGaugeVec("last_run_timestamp_seconds", "Time of the last run.")
+	latency := r.NewHistogramVec("request_duration_seconds", "Request latency.", []float64{1, 0.1, 0.5}, "endpoint")
+
+	// Series are created out of order and written sorted by label values.
+	runs.With("success", "cron").Add(2)
+	runs.With(`fail "quoted"`+"\n"+`C:\path`, "http").Inc()
+	runs.With("failed", "cron").Inc()
+	runs.With("failed", "cron").Add(-1) // ignored
+	last.With().Set(1700000000.5)
+	latency.With("/runs").Observe(0.05)
+	latency.With("/healthz").Observe(0.3)
+	latency.With("/runs").Observe(0.7)
+	latency.With("/runs").Observe(3)
+
+	const want = `# HELP runs_total Runs by result.\nCounted once per run, see C:\\docs.
+# TYPE runs_total counter
+runs_total{result="fail \"quoted\"\nC:\\path",trigger="http"} 1
+runs_total{result="failed",trigger="cron"} 1
+runs_total{result="success",trigger="cron"} 2
+# HELP last_run_timestamp_seconds Time of the last run.
+# TYPE last_run_timestamp_seconds gauge
+last_run_timestamp_seconds 1.7000000005e+09
+# HELP request_duration_seconds Request latency.
+# TYPE request_duration_seconds histogram
+request_duration_seconds_bucket{endpoint="/healthz",le="0.1"} 0
+request_duration_seconds_bucket{endpoint="/healthz",le="0.5"} 1
+request_duration_seconds_bucket{endpoint="/healthz",le="1"} 1
+request_duration_seconds_bucket{endpoint="/healthz",le="+Inf"} 1
+request_duration_seconds_sum{endpoint="/healthz"} 0.3
+request_duration_seconds_count{endpoint="/healthz"} 1
+request_duration_seconds_bucket{endpoint="/runs",le="0.1"} 1
+request_duration_seconds_bucket{endpoint="/runs",le="0.5"} 1
+request_duration_seconds_bucket{endpoint="/runs",le="1"} 2
+request_duration_seconds_bucket{endpoint="/runs",le="+Inf"} 3
+request_duration_seconds_sum{endpoint="/runs"} 3.75
+request_duration_seconds_count{endpoint="/runs"} 3
+`
+	// Writing twice checks that the order is stable across scrapes.
+	for i := 0; i < 2; i++ {
+		var b strings.Builder
+		if err := r.WriteText(&b); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != want {
+			t.Fatalf("scrape %d:\n%s\nwant:\n%s", i, got, want)
+		}
+	}
+}
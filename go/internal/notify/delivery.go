@@ -0,0 +1,158 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+
+	"skland-daily-attendance-go/internal/logging"
+	"skland-daily-attendance-go/internal/metrics"
+	"skland-daily-attendance-go/internal/tracing"
+)
+
+const (
+	// deliveryAttempts is how often a target is tried before giving up.
+	deliveryAttempts = 3
+	// deliveryBackoff is the delay before the first retry; it doubles after
+	// every failed attempt.
+	deliveryBackoff = 2 * time.Second
+)
+
+// TargetError describes a failed delivery to a single target.
+type TargetError struct {
+	// Target is a description of the target that is safe to log: the
+	// provider name and host, without credentials, paths or tokens.
+	Target string
+	Err    error
+}
+
+func (e *TargetError) Error() string {
+	return e.Target + ": " + e.Err.Error()
+}
+
+func (e *TargetError) Unwrap() error {
+	return e.Err
+}
+
+// DeliveryError aggregates the failures of a push to multiple targets.
+type DeliveryError struct {
+	Failures []*TargetError
+	// Total is the number of targets the push was attempted on.
+	Total int
+}
+
+func (e *DeliveryError) Error() string {
+	parts := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		parts = append(parts, f.Error())
+	}
+	return fmt.Sprintf("notification delivery failed for %d/%d targets: %s", len(e.Failures), e.Total, strings.Join(parts, "; "))
+}
+
+func (e *DeliveryError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		errs = append(errs, f)
+	}
+	return errs
+}
+
+// describeTarget returns a loggable description of a notification URL.
+func describeTarget(name, rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return name
+	}
+	return name + "(" + u.Hostname() + ")"
+}
+
+// redactURLError strips the request URL that net/http includes in its
+// errors, since webhook URLs usually embed secrets.
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s request: %w", urlErr.Op, urlErr.Err)
+	}
+	return err
+}
+
+// sendWithRetry sends the report, retrying transient failures with
+// exponential backoff.
+func sendWithRetry(ctx context.Context, p provider, report *Report) error {
+	backoff := deliveryBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = p.Send(ctx, report)
+		if err == nil || attempt >= deliveryAttempts || !isTransient(err) {
+			return err
+		}
+		logging.FromContext(ctx).Debug("通知推送失败，稍后重试", "attempt", attempt, "backoff", backoff, "err", redactURLError(err))
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// send delivers r to the target with retries and records the result.
+func (t *target) send(ctx context.Context, r *Report) error {
+	ctx, span := tracing.Start(ctx, "notify.deliver", tracing.WithAttributes(
+		slog.String("notify.provider", t.name),
+		slog.String("notify.target", t.label),
+	))
+	defer span.End()
+
+	start := time.Now()
+	err := sendWithRetry(ctx, t.provider, r)
+	recordDelivery(t.name, err)
+	span.RecordError(redactURLError(err))
+
+	log := logging.FromContext(ctx).With("target", t.label, "provider", t.name, "duration", time.Since(start))
+	if err != nil {
+		log.Warn("通知推送失败", "err", redactURLError(err))
+	} else {
+		log.Debug("通知推送成功")
+	}
+	return err
+}
+
+func recordDelivery(provider string, err error) {
+	if provider == "" {
+		provider = "unknown"
+	}
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	metrics.NotificationDeliveries.With(provider, result).Inc()
+}
+
+// isTransient reports whether a delivery error is worth retrying: network
+// failures, server errors and temporary SMTP replies. Rate limiting is not,
+// as postJSON has already waited out and retried 429 responses.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == 408
+	}
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 400 && smtpErr.Code < 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
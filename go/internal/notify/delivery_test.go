@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSendWithRetryRateLimited(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	p, err := newWebhookProvider(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sendWithRetry(context.Background(), p, sampleReport())
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("sendWithRetry = %v, want 429 StatusError", err)
+	}
+	// Only postJSON retries rate limiting; the delivery loop must not
+	// repeat its retries.
+	if got, want := requests.Load(), int32(1+maxRateLimitRetries); got != want {
+		t.Errorf("server got %d requests, want %d", got, want)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&StatusError{StatusCode: 500}, true},
+		{&StatusError{StatusCode: 503}, true},
+		{&StatusError{StatusCode: 408}, true},
+		{&StatusError{StatusCode: 429}, false},
+		{&StatusError{StatusCode: 400}, false},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, true},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("isTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
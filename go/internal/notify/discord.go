@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+	"unicode/utf8"
+)
+
+// Discord embed limits, see https://discord.com/developers/docs/resources/message#embed-object-embed-limits
+const (
+	discordDescriptionLimit = 4096
+	discordFieldValueLimit  = 1024
+	discordMaxFields        = 25
+	discordEmbedTotalLimit  = 6000
+
+	discordColorSuccess = 0x2ECC71
+	discordColorFailure = 0xE74C3C
+)
+
+// discordProvider posts the report as a Discord webhook embed.
+//
+// URL format: discord://discord.com/api/webhooks/<id>/<token>, or the plain
+// https:// webhook URL copied from Discord.
+type discordProvider struct {
+	url    string
+	client *http.Client
+}
+
+func newDiscordProvider(u *url.URL) (provider, error) {
+	return &discordProvider{
+		url: httpsURL(u),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+}
+
+// Send posts the report to the Discord webhook.
+func (p *discordProvider) Send(ctx context.Context, report *Report) error {
+	embed := discordEmbed{
+		Title:     truncate(report.Title, 256),
+		Color:     discordColorSuccess,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	if report.HasError() {
+		embed.Color = discordColorFailure
+	}
+
+	if s := report.Summary; s != nil {
+		embed.Fields = append(embed.Fields, discordField{
+			Name:  "账号统计",
+			Value: truncate(s.accountLine(), discordFieldValueLimit),
+		})
+		for _, g := range s.Games {
+			if len(embed.Fields) >= discordMaxFields {
+				break
+			}
+			embed.Fields = append(embed.Fields, discordField{
+				Name:   fmt.Sprintf("【%s】角色统计", g.Name()),
+				Value:  truncate(g.gameLine(), discordFieldValueLimit),
+				Inline: true,
+			})
+		}
+	}
+
+	// The description gets whatever remains of the total embed budget.
+	budget := discordEmbedTotalLimit - utf8.RuneCountInString(embed.Title)
+	for _, f := range embed.Fields {
+		budget -= utf8.RuneCountInString(f.Name) + utf8.RuneCountInString(f.Value)
+	}
+	budget = min(budget, discordDescriptionLimit)
+	embed.Description = truncate(report.Text(), budget)
+
+	payload := map[string]any{
+		"embeds": []discordEmbed{embed},
+	}
+	return postJSON(ctx, p.client, p.url, nil, payload)
+}
+
+// httpsURL turns a provider specific scheme such as discord:// back into the
+// https:// URL of the underlying webhook.
+func httpsURL(u *url.URL) string {
+	out := *u
+	if out.Scheme != "http" {
+		out.Scheme = "https"
+	}
+	return out.String()
+}
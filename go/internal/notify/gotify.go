@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// gotifyProvider pushes the report to a Gotify server as an application message.
+//
+// URL format: gotify://host[:port][/path]?token=<app token>
+//
+// Query parameters:
+//   - token: application token (required)
+//   - priority: defaults to 5
+//   - error_priority: priority used when any message is an error, defaults to 8
+//   - scheme: http to talk to servers without TLS, defaults to https
+type gotifyProvider struct {
+	url           string
+	priority      int
+	errorPriority int
+	header        http.Header
+	client        *http.Client
+}
+
+func newGotifyProvider(u *url.URL) (provider, error) {
+	q := u.Query()
+	p := &gotifyProvider{
+		priority:      5,
+		errorPriority: 8,
+		header:        http.Header{},
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+	if u.Host == "" {
+		return nil, errors.New("gotify: missing host")
+	}
+	token := q.Get("token")
+	if token == "" {
+		return nil, errors.New("gotify: missing application token, set token=")
+	}
+	p.header.Set("X-Gotify-Key", token)
+
+	var err error
+	if p.priority, err = parsePriority(q.Get("priority"), p.priority, 0, 10); err != nil {
+		return nil, fmt.Errorf("gotify: %w", err)
+	}
+	if p.errorPriority, err = parsePriority(q.Get("error_priority"), p.errorPriority, 0, 10); err != nil {
+		return nil, fmt.Errorf("gotify: %w", err)
+	}
+
+	p.url = serverURL(u, q.Get("scheme"), strings.TrimSuffix(u.Path, "/")+"/message")
+	return p, nil
+}
+
+// Send pushes the report to Gotify.
+func (p *gotifyProvider) Send(ctx context.Context, report *Report) error {
+	priority := p.priority
+	if report.HasError() {
+		priority = max(priority, p.errorPriority)
+	}
+
+	payload := map[string]any{
+		"title":    report.Title,
+		"message":  report.Text(),
+		"priority": priority,
+	}
+	return postJSON(ctx, p.client, p.url, p.header, payload)
+}
@@ -0,0 +1,138 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxRateLimitRetries bounds how often a 429 response is retried.
+	maxRateLimitRetries = 3
+	// maxRetryAfter caps the delay a server may ask us to wait.
+	maxRetryAfter = 30 * time.Second
+)
+
+// postJSON posts payload as JSON to url with the extra headers. When the target responds with 429 it
+// waits for the delay requested via the Retry-After header or a JSON
+// retry_after field (as Discord does) and tries again.
+func postJSON(ctx context.Context, client *http.Client, url string, header http.Header, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		// Drain the rest so the connection can be reused.
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		if resp.StatusCode != http.StatusTooManyRequests || attempt >= maxRateLimitRetries {
+			return &StatusError{
+				StatusCode: resp.StatusCode,
+				Status:     resp.Status,
+				Body:       truncate(string(respBody), 200),
+			}
+		}
+
+		timer := time.NewTimer(retryAfter(resp.Header, respBody))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// StatusError is returned when a target responds with a non-2xx status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return "unexpected status " + e.Status
+	}
+	return fmt.Sprintf("unexpected status %s: %s", e.Status, e.Body)
+}
+
+// retryAfter extracts the rate-limit delay from a 429 response.
+func retryAfter(header http.Header, body []byte) time.Duration {
+	delay := time.Second
+	if v := header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil {
+			delay = time.Duration(secs * float64(time.Second))
+		} else if t, err := http.ParseTime(v); err == nil {
+			delay = time.Until(t)
+		}
+	} else {
+		var rl struct {
+			RetryAfter float64 `json:"retry_after"`
+		}
+		if json.Unmarshal(body, &rl) == nil && rl.RetryAfter > 0 {
+			delay = time.Duration(rl.RetryAfter * float64(time.Second))
+		}
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > maxRetryAfter {
+		delay = maxRetryAfter
+	}
+	return delay
+}
+
+// truncateBytes shortens s to at most max bytes without splitting a UTF-8
+// sequence, for services whose limits are expressed in bytes.
+func truncateBytes(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	const ellipsis = "…"
+	cut := max - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	if cut < 0 {
+		return ""
+	}
+	return s[:cut] + ellipsis
+}
+
+// truncate shortens s to at most max runes, marking the cut with an ellipsis.
+func truncate(s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
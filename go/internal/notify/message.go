@@ -0,0 +1,109 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Level is the severity of a message.
+type Level int
+
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the lower-case name of the level.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// ParseLevel parses a level name such as "info" or "error".
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown level %q", s)
+	}
+}
+
+// Stage identifies which step of the run a message comes from.
+type Stage string
+
+const (
+	StageRun        Stage = "run"
+	StageAccount    Stage = "account"
+	StageAuth       Stage = "auth"
+	StageBinding    Stage = "binding"
+	StageAttendance Stage = "attendance"
+)
+
+// ErrorKind classifies a failure so that downstream consumers can group
+// and filter errors without parsing message text.
+type ErrorKind string
+
+const (
+	ErrorAuth       ErrorKind = "auth"
+	ErrorBinding    ErrorKind = "binding"
+	ErrorAttendance ErrorKind = "attendance"
+	ErrorTimeout    ErrorKind = "timeout"
+)
+
+// Message is a structured notification event collected during a run. Text
+// is always set so simple providers can render the message as a plain line.
+type Message struct {
+	Text  string
+	Level Level
+	Time  time.Time
+	Stage Stage
+	// Account is the 1-based index of the account the message belongs to,
+	// or 0 for messages about the run as a whole.
+	Account      int
+	AccountLabel string
+	GameID       int
+	GameName     string
+	Character    string
+	Rewards      []string
+	ErrorKind    ErrorKind
+}
+
+// IsError reports whether the message describes a failure.
+func (m Message) IsError() bool {
+	return m.Level >= LevelError
+}
@@ -1,86 +1,417 @@
 package notify
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"net/http"
+	"fmt"
+	"sync"
 	"time"
+
+	"skland-daily-attendance-go/internal/config"
+	"skland-daily-attendance-go/internal/logging"
+	"skland-daily-attendance-go/internal/metrics"
+	"skland-daily-attendance-go/internal/storage"
 )
 
-// Message represents a collected log entry.
-type Message struct {
-	Text   string
-	IsError bool
+// Notifier starts a notification session per run, so that concurrent runs
+// never see each other's messages.
+type Notifier interface {
+	Begin(runID string) Session
 }
 
-// Notifier collects messages and pushes them to multiple endpoints.
-type Notifier interface {
+// Session collects the messages of a single run and pushes them to multiple
+// endpoints. Implementations are safe for concurrent use.
+type Session interface {
 	Collect(msg Message)
+	// Summarize attaches the execution summary of the run to the report.
+	Summarize(summary Summary)
+	// Push delivers the collected report and resets the session.
 	Push(ctx context.Context) error
 }
 
-// WebhookNotifier posts collected messages as a single payload to multiple URLs.
-type WebhookNotifier struct {
-	urls     []string
-	messages []Message
-	client   *http.Client
+// Report is everything collected during a run, handed to each provider and
+// used as the data of notification templates.
+type Report struct {
+	RunID    string
+	Title    string
+	Messages []Message
+	Summary  *Summary
+	// AccountTotal is the number of configured accounts, which may exceed
+	// the accounts in a report routed to a single recipient.
+	AccountTotal int
+
+	// text is the report rendered with the template selected for the provider.
+	text string
+}
+
+// AccountSection groups the messages of a single account.
+type AccountSection struct {
+	Index    int
+	Total    int
+	Label    string
+	Messages []Message
+	HasError bool
+}
+
+// Text returns the report rendered with the provider's template.
+func (r *Report) Text() string {
+	return r.text
+}
+
+// General returns the messages that do not belong to any account.
+func (r *Report) General() []Message {
+	var out []Message
+	for _, m := range r.Messages {
+		if m.Account == 0 {
+			out = append(out, m)
+		}
+	}
+	return out
 }
 
-// NewWebhookNotifier creates a new notifier for the given URLs.
-func NewWebhookNotifier(urls []string) *WebhookNotifier {
-	return &WebhookNotifier{
-		urls: urls,
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+// Accounts returns the messages grouped by account, in account order.
+func (r *Report) Accounts() []AccountSection {
+	total := r.AccountTotal
+	if total == 0 && r.Summary != nil {
+		total = r.Summary.Accounts.Total
 	}
+	var sections []AccountSection
+	byIndex := make(map[int]int)
+	for _, m := range r.Messages {
+		if m.Account == 0 {
+			continue
+		}
+		i, ok := byIndex[m.Account]
+		if !ok {
+			i = len(sections)
+			byIndex[m.Account] = i
+			sections = append(sections, AccountSection{Index: m.Account, Label: m.AccountLabel})
+		}
+		sections[i].Messages = append(sections[i].Messages, m)
+		sections[i].HasError = sections[i].HasError || m.IsError()
+		total = max(total, m.Account)
+	}
+	for i := range sections {
+		sections[i].Total = total
+	}
+	return sections
 }
 
-// Collect appends a message to the buffer.
-func (n *WebhookNotifier) Collect(msg Message) {
-	n.messages = append(n.messages, msg)
+// Duration returns how long the run took, or 0 when unknown.
+func (r *Report) Duration() time.Duration {
+	if r.Summary == nil || r.Summary.StartedAt.IsZero() {
+		return 0
+	}
+	return r.Summary.FinishedAt.Sub(r.Summary.StartedAt)
 }
 
-// Push sends all collected messages to each configured URL.
-func (n *WebhookNotifier) Push(ctx context.Context) error {
-	if len(n.urls) == 0 || len(n.messages) == 0 {
-		return nil
+// HasError reports whether any collected message is an error.
+func (r *Report) HasError() bool {
+	for _, m := range r.Messages {
+		if m.IsError() {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookNotifier collects messages and delivers them as a single report to
+// multiple targets. The provider for each target is selected by URL scheme.
+//
+// The notifier itself is immutable after construction and safe for
+// concurrent use; messages are collected by the per-run sessions it begins.
+type WebhookNotifier struct {
+	targets   []target
+	templates *Templates
+	store     storage.Store
+	// exclusive holds accounts routed only to their own targets.
+	exclusive map[int]bool
+}
+
+type target struct {
+	mu       sync.Mutex
+	name     string
+	label    string
+	key      string
+	scope    scope
+	policy   Policy
+	provider provider
+	err      error
+}
+
+// Options configures a WebhookNotifier.
+type Options struct {
+	// Templates renders the reports; nil uses the built-in templates.
+	Templates *Templates
+	// Store keeps the state of on-change policies and deferred reports;
+	// without it on-change behaves like always and quiet hours drop reports.
+	Store storage.Store
+	// Policy is the default policy of targets without their own settings.
+	Policy Policy
+	// Location is used for quiet hours given in notification URLs.
+	Location *time.Location
+	// Routes adds per-account and admin targets on top of the global URLs.
+	Routes Routes
+}
+
+// New creates a notifier for the notification settings in cfg.
+func New(cfg *config.Config, store storage.Store) (*WebhookNotifier, error) {
+	templates, err := ParseTemplates(cfg.NotificationTemplates)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := ParsePolicy(cfg.NotificationPolicy, cfg.NotificationMinLevel, cfg.NotificationQuietHours, cfg.Location)
+	if err != nil {
+		return nil, err
 	}
+	urls := cfg.NotificationURLs
+	if ql := cfg.QingLong; ql.Notify {
+		u, err := qinglongURL(ql.URL, ql.ClientID, ql.ClientSecret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid QL_URL: %w", err)
+		}
+		urls = append(append([]string(nil), urls...), u)
+	}
+	return NewWebhookNotifier(urls, Options{
+		Templates: templates,
+		Store:     store,
+		Policy:    policy,
+		Location:  cfg.Location,
+		Routes:    routesFromConfig(cfg),
+	}), nil
+}
 
-	type payloadMessage struct {
-		Text    string `json:"text"`
-		IsError bool   `json:"isError"`
+// NewWebhookNotifier creates a new notifier for the given global URLs plus
+// the routes in opts. A URL listed several times is a single recipient
+// receiving the union of its sections. Policy parameters in a URL (policy,
+// min_level, quiet_hours) override opts.Policy for that target.
+func NewWebhookNotifier(urls []string, opts Options) *WebhookNotifier {
+	templates := opts.Templates
+	if templates == nil {
+		templates, _ = ParseTemplates(nil)
+	}
+	n := &WebhookNotifier{
+		templates: templates,
+		store:     opts.Store,
+		exclusive: make(map[int]bool),
 	}
-	var payload struct {
-		Title    string           `json:"title"`
-		Messages []payloadMessage `json:"messages"`
+	for _, r := range opts.Routes.Accounts {
+		if r.Exclusive {
+			n.exclusive[r.Account] = true
+		}
 	}
-	payload.Title = "森空岛每日签到"
-	for _, m := range n.messages {
-		payload.Messages = append(payload.Messages, payloadMessage{
-			Text:    m.Text,
-			IsError: m.IsError,
+
+	for _, rs := range recipients(urls, opts.Routes) {
+		raw := rs.url
+		u, policy, err := targetPolicy(raw, opts.Policy, opts.Location)
+		var (
+			p    provider
+			name string
+		)
+		if err == nil {
+			p, name, err = newProvider(u)
+		}
+		n.targets = append(n.targets, target{
+			name:     name,
+			label:    describeTarget(name, u),
+			key:      u,
+			scope:    rs.scope,
+			policy:   policy,
+			provider: p,
+			err:      err,
 		})
 	}
+	return n
+}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return err
+// Begin starts the notification session of a single run.
+func (n *WebhookNotifier) Begin(runID string) Session {
+	return &session{
+		notifier: n,
+		buf:      newBuffer(runID),
+	}
+}
+
+// FlushDeferred delivers reports deferred by quiet hours that have ended,
+// without sending a new report.
+func (n *WebhookNotifier) FlushDeferred(ctx context.Context) error {
+	return n.deliverAll(ctx, nil)
+}
+
+// deliverAll delivers the collected buffer to every target concurrently,
+// according to each target's policy. Reports deferred by quiet hours that
+// have ended are delivered first. A nil buffer only flushes deferred reports.
+func (n *WebhookNotifier) deliverAll(ctx context.Context, buf *buffer) error {
+	if len(n.targets) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	errs := make([]error, len(n.targets))
+	var wg sync.WaitGroup
+	for i := range n.targets {
+		t := &n.targets[i]
+		if t.err != nil {
+			errs[i] = t.err
+			recordDelivery(t.name, t.err)
+			continue
+		}
+		wg.Add(1)
+		var report *Report
+		if buf != nil {
+			report = buf.reportFor(t, n.exclusive)
+		}
+		go func(i int, t *target, report *Report) {
+			defer wg.Done()
+			errs[i] = n.deliver(ctx, t, report, now)
+		}(i, t, report)
 	}
+	wg.Wait()
 
-	for _, url := range n.urls {
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	var failures []*TargetError
+	for i, err := range errs {
 		if err != nil {
-			return err
+			failures = append(failures, &TargetError{Target: n.targets[i].label, Err: redactURLError(err)})
 		}
-		req.Header.Set("Content-Type", "application/json")
+	}
+	if len(failures) > 0 {
+		return &DeliveryError{Failures: failures, Total: len(n.targets)}
+	}
+	return nil
+}
 
-		if _, err := n.client.Do(req); err != nil {
+// deliver applies the target's policy to the report and sends it, or defers
+// it during quiet hours. A nil report only flushes deferred reports.
+func (n *WebhookNotifier) deliver(ctx context.Context, t *target, report *Report, now time.Time) error {
+	// Sessions may push concurrently; serialise the state kept per target.
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	quiet := t.policy.QuietHours != nil && t.policy.QuietHours.Contains(now)
+	if !quiet {
+		if err := n.flushDeferred(ctx, t); err != nil {
 			return err
 		}
 	}
+	if report == nil || !n.shouldSend(t, report) {
+		return nil
+	}
 
+	r, err := n.prepare(t, report)
+	if err != nil {
+		return err
+	}
+	if quiet {
+		if err := n.deferReport(t, r); err != nil {
+			return err
+		}
+		metrics.NotificationDeliveries.With(t.name, "deferred").Inc()
+		logging.FromContext(ctx).Info("免打扰时段，通知已暂存", "target", t.label, "provider", t.name)
+	} else if err := t.send(ctx, r); err != nil {
+		return err
+	}
+	n.recordOutcome(t, report)
 	return nil
 }
 
+// shouldSend evaluates the policy mode of the target.
+func (n *WebhookNotifier) shouldSend(t *target, report *Report) bool {
+	switch t.policy.Mode {
+	case PolicyOnFailure:
+		return failed(report)
+	case PolicyOnChange:
+		if n.store == nil {
+			return true
+		}
+		prev, ok, err := n.store.Get(storage.GenerateNotificationKey("outcome", t.key))
+		return err != nil || !ok || prev != outcome(report)
+	default:
+		return true
+	}
+}
+
+func (n *WebhookNotifier) recordOutcome(t *target, report *Report) {
+	if n.store == nil || t.policy.Mode != PolicyOnChange {
+		return
+	}
+	_ = n.store.Set(storage.GenerateNotificationKey("outcome", t.key), outcome(report))
+}
+
+// prepare filters the report by the target's minimum level and renders it
+// with the target's template.
+func (n *WebhookNotifier) prepare(t *target, report *Report) (*Report, error) {
+	r := *report
+	r.Messages = filterLevel(report.Messages, t.policy.MinLevel)
+	text, err := n.templates.render(t.name, defaultTemplateFor(t.name), &r)
+	if err != nil {
+		return nil, err
+	}
+	r.text = text
+	return &r, nil
+}
+
+// deferredReport is a rendered report waiting for quiet hours to end.
+type deferredReport struct {
+	Title    string
+	Text     string
+	Messages []Message
+	Summary  *Summary
+}
+
+// deferReport appends a rendered report to the target's deferred queue.
+func (n *WebhookNotifier) deferReport(t *target, r *Report) error {
+	if n.store == nil {
+		return nil
+	}
+	key := storage.GenerateNotificationKey("deferred", t.key)
+	queue, err := n.loadDeferred(key)
+	if err != nil {
+		return err
+	}
+	queue = append(queue, deferredReport{
+		Title:    r.Title,
+		Text:     r.text,
+		Messages: r.Messages,
+		Summary:  r.Summary,
+	})
+	b, err := json.Marshal(queue)
+	if err != nil {
+		return err
+	}
+	return n.store.Set(key, string(b))
+}
+
+// flushDeferred sends the target's deferred reports in order, keeping the
+// ones that could not be delivered for the next attempt.
+func (n *WebhookNotifier) flushDeferred(ctx context.Context, t *target) error {
+	if n.store == nil {
+		return nil
+	}
+	key := storage.GenerateNotificationKey("deferred", t.key)
+	queue, err := n.loadDeferred(key)
+	if err != nil || len(queue) == 0 {
+		return err
+	}
+
+	for i, d := range queue {
+		r := &Report{Title: d.Title, Messages: d.Messages, Summary: d.Summary, text: d.Text}
+		if err := t.send(ctx, r); err != nil {
+			b, _ := json.Marshal(queue[i:])
+			_ = n.store.Set(key, string(b))
+			return fmt.Errorf("deliver deferred report: %w", err)
+		}
+	}
+	return n.store.Delete(key)
+}
+
+func (n *WebhookNotifier) loadDeferred(key string) ([]deferredReport, error) {
+	v, ok, err := n.store.Get(key)
+	if err != nil || !ok || v == "" {
+		return nil, err
+	}
+	var queue []deferredReport
+	if err := json.Unmarshal([]byte(v), &queue); err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ntfyMessageLimit is the message size above which ntfy turns the body into
+// an attachment instead of showing it.
+const ntfyMessageLimit = 4096
+
+// ntfyProvider publishes the report to an ntfy topic.
+//
+// URL format: ntfy://[user:pass@]host[:port]/topic
+//
+// Query parameters:
+//   - priority: 1-5, defaults to 3
+//   - error_priority: priority used when any message is an error, defaults to 5
+//   - tags: comma separated tags or emoji short codes
+//   - token: access token, sent as a bearer token instead of basic auth
+//   - scheme: http to talk to servers without TLS, defaults to https
+type ntfyProvider struct {
+	url           string
+	topic         string
+	priority      int
+	errorPriority int
+	tags          []string
+	header        http.Header
+	client        *http.Client
+}
+
+func newNtfyProvider(u *url.URL) (provider, error) {
+	q := u.Query()
+	p := &ntfyProvider{
+		topic:         strings.Trim(u.Path, "/"),
+		priority:      3,
+		errorPriority: 5,
+		header:        http.Header{},
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+	if u.Host == "" || p.topic == "" {
+		return nil, errors.New("ntfy: url must be ntfy://host/topic")
+	}
+
+	var err error
+	if p.priority, err = parsePriority(q.Get("priority"), p.priority, 1, 5); err != nil {
+		return nil, fmt.Errorf("ntfy: %w", err)
+	}
+	if p.errorPriority, err = parsePriority(q.Get("error_priority"), p.errorPriority, 1, 5); err != nil {
+		return nil, fmt.Errorf("ntfy: %w", err)
+	}
+	for _, tag := range strings.Split(q.Get("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			p.tags = append(p.tags, tag)
+		}
+	}
+
+	if token := q.Get("token"); token != "" {
+		p.header.Set("Authorization", "Bearer "+token)
+	} else if u.User != nil {
+		password, _ := u.User.Password()
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(u.User.Username(), password)
+		p.header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+
+	// JSON publishing goes to the server root with the topic in the body.
+	p.url = serverURL(u, q.Get("scheme"), "/")
+	return p, nil
+}
+
+// Send publishes the report to the ntfy topic.
+func (p *ntfyProvider) Send(ctx context.Context, report *Report) error {
+	priority := p.priority
+	tags := append([]string{"white_check_mark"}, p.tags...)
+	if report.HasError() {
+		priority = max(priority, p.errorPriority)
+		tags[0] = "x"
+	}
+
+	payload := map[string]any{
+		"topic":    p.topic,
+		"title":    report.Title,
+		"message":  truncateBytes(report.Text(), ntfyMessageLimit),
+		"priority": priority,
+		"tags":     tags,
+	}
+	return postJSON(ctx, p.client, p.url, p.header, payload)
+}
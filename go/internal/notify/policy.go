@@ -0,0 +1,175 @@
+package notify
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// PolicyMode decides whether a report is delivered to a target at all.
+type PolicyMode string
+
+const (
+	// PolicyAlways delivers every report.
+	PolicyAlways PolicyMode = "always"
+	// PolicyOnFailure delivers only reports of runs with failures.
+	PolicyOnFailure PolicyMode = "on-failure"
+	// PolicyOnChange delivers only when the outcome differs from the
+	// previous run, as recorded in the store.
+	PolicyOnChange PolicyMode = "on-change"
+)
+
+// Policy controls when and what is delivered to a single target.
+type Policy struct {
+	Mode PolicyMode
+	// MinLevel drops messages below this level from the report.
+	MinLevel Level
+	// QuietHours defers delivery while the current time is inside the window.
+	QuietHours *QuietHours
+}
+
+// Query parameters that configure the policy of a single target. They are
+// stripped from the URL before it is handed to the provider.
+const (
+	paramPolicy     = "policy"
+	paramMinLevel   = "min_level"
+	paramQuietHours = "quiet_hours"
+)
+
+// ParsePolicy parses a policy from its textual settings. Empty values keep
+// the defaults: always deliver, info level, no quiet hours.
+func ParsePolicy(mode, minLevel, quietHours string, loc *time.Location) (Policy, error) {
+	p := Policy{Mode: PolicyAlways, MinLevel: LevelInfo}
+	return p.override(mode, minLevel, quietHours, loc)
+}
+
+// override returns a copy of p with the non-empty settings applied.
+func (p Policy) override(mode, minLevel, quietHours string, loc *time.Location) (Policy, error) {
+	if mode != "" {
+		switch m := PolicyMode(strings.ToLower(mode)); m {
+		case PolicyAlways, PolicyOnFailure, PolicyOnChange:
+			p.Mode = m
+		default:
+			return p, fmt.Errorf("unknown notification policy %q", mode)
+		}
+	}
+	if minLevel != "" {
+		level, err := ParseLevel(minLevel)
+		if err != nil {
+			return p, err
+		}
+		p.MinLevel = level
+	}
+	if quietHours != "" {
+		q, err := ParseQuietHours(quietHours, loc)
+		if err != nil {
+			return p, err
+		}
+		p.QuietHours = q
+	}
+	return p, nil
+}
+
+// targetPolicy applies the policy parameters of a notification URL on top of
+// the default policy and returns the URL without them.
+func targetPolicy(rawURL string, def Policy, loc *time.Location) (string, Policy, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL, def, nil
+	}
+	q := u.Query()
+	if !q.Has(paramPolicy) && !q.Has(paramMinLevel) && !q.Has(paramQuietHours) {
+		return rawURL, def, nil
+	}
+	p, err := def.override(q.Get(paramPolicy), q.Get(paramMinLevel), q.Get(paramQuietHours), loc)
+	q.Del(paramPolicy)
+	q.Del(paramMinLevel)
+	q.Del(paramQuietHours)
+	u.RawQuery = q.Encode()
+	return u.String(), p, err
+}
+
+// QuietHours is a daily time window such as 23:00-07:00, which may wrap
+// around midnight.
+type QuietHours struct {
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+// ParseQuietHours parses a window in HH:MM-HH:MM form.
+func ParseQuietHours(s string, loc *time.Location) (*QuietHours, error) {
+	start, end, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid quiet hours %q, want HH:MM-HH:MM", s)
+	}
+	q := &QuietHours{Location: loc}
+	var err error
+	if q.Start, err = parseClock(start); err != nil {
+		return nil, fmt.Errorf("invalid quiet hours %q: %w", s, err)
+	}
+	if q.End, err = parseClock(end); err != nil {
+		return nil, fmt.Errorf("invalid quiet hours %q: %w", s, err)
+	}
+	if q.Location == nil {
+		q.Location = time.Local
+	}
+	return q, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls inside the quiet window.
+func (q *QuietHours) Contains(t time.Time) bool {
+	t = t.In(q.Location)
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if q.Start <= q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
+
+// NextEnd returns the first end of the quiet window after t.
+func (q *QuietHours) NextEnd(t time.Time) time.Time {
+	t = t.In(q.Location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, q.Location)
+	end := midnight.Add(q.End)
+	if !end.After(t) {
+		end = midnight.AddDate(0, 0, 1).Add(q.End)
+	}
+	return end
+}
+
+// filterLevel returns the messages at or above min.
+func filterLevel(messages []Message, min Level) []Message {
+	out := make([]Message, 0, len(messages))
+	for _, m := range messages {
+		if m.Level >= min {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// outcome fingerprints the result of a run for the on-change policy.
+func outcome(report *Report) string {
+	if report.Summary == nil {
+		if report.HasError() {
+			return "failed"
+		}
+		return "success"
+	}
+	return fmt.Sprintf("%s:%v", report.Summary.Result, report.Summary.Accounts.FailedIndexes)
+}
+
+// failed reports whether the run the report describes had failures.
+func failed(report *Report) bool {
+	return report.HasError() || (report.Summary != nil && report.Summary.Result == "failed")
+}
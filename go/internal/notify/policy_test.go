@@ -0,0 +1,224 @@
+package notify
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"skland-daily-attendance-go/internal/storage"
+)
+
+func TestQuietHours(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*60*60)
+	at := func(day, hour, minute int) time.Time { return time.Date(2025, 3, day, hour, minute, 0, 0, loc) }
+	tests := []struct {
+		window   string
+		t        time.Time
+		contains bool
+		nextEnd  time.Time
+	}{
+		// Wrapping midnight.
+		{"23:00-07:00", at(10, 22, 59), false, at(11, 7, 0)},
+		{"23:00-07:00", at(10, 23, 0), true, at(11, 7, 0)},
+		{"23:00-07:00", at(11, 0, 30), true, at(11, 7, 0)},
+		{"23:00-07:00", at(11, 6, 59), true, at(11, 7, 0)},
+		{"23:00-07:00", at(11, 7, 0), false, at(12, 7, 0)},
+		// Within a day.
+		{"12:00-13:30", at(10, 11, 59), false, at(10, 13, 30)},
+		{"12:00-13:30", at(10, 12, 0), true, at(10, 13, 30)},
+		{"12:00-13:30", at(10, 13, 30), false, at(11, 13, 30)},
+		// Times are compared in the location of the window.
+		{"23:00-07:00", time.Date(2025, 3, 10, 16, 0, 0, 0, time.UTC), true, at(11, 7, 0)},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuietHours(tt.window, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := q.Contains(tt.t); got != tt.contains {
+			t.Errorf("%s contains %s = %v, want %v", tt.window, tt.t, got, tt.contains)
+		}
+		if got := q.NextEnd(tt.t); !got.Equal(tt.nextEnd) {
+			t.Errorf("%s next end after %s = %s, want %s", tt.window, tt.t, got, tt.nextEnd)
+		}
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		mode, minLevel, quietHours string
+		want                       Policy
+		wantErr                    bool
+	}{
+		{want: Policy{Mode: PolicyAlways, MinLevel: LevelInfo}},
+		{mode: "On-Change", minLevel: "warning", want: Policy{Mode: PolicyOnChange, MinLevel: LevelWarn}},
+		{mode: "on-failure", minLevel: "error", want: Policy{Mode: PolicyOnFailure, MinLevel: LevelError}},
+		{mode: "sometimes", wantErr: true},
+		{minLevel: "loud", wantErr: true},
+		{quietHours: "23:00", wantErr: true},
+		{quietHours: "25:00-07:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePolicy(tt.mode, tt.minLevel, tt.quietHours, time.UTC)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePolicy(%q, %q, %q) error = %v, want error %v", tt.mode, tt.minLevel, tt.quietHours, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("ParsePolicy(%q, %q, %q) = %+v, want %+v", tt.mode, tt.minLevel, tt.quietHours, got, tt.want)
+		}
+	}
+}
+
+func TestTargetPolicy(t *testing.T) {
+	def := Policy{Mode: PolicyOnFailure, MinLevel: LevelInfo}
+	raw, p, err := targetPolicy("https://example.com/hook?token=x&policy=always&min_level=warn&quiet_hours=23:00-07:00", def, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw != "https://example.com/hook?token=x" {
+		t.Errorf("url = %q, want the policy parameters stripped", raw)
+	}
+	if p.Mode != PolicyAlways || p.MinLevel != LevelWarn || p.QuietHours == nil || p.QuietHours.Start != 23*time.Hour {
+		t.Errorf("policy = %+v", p)
+	}
+
+	raw, p, err = targetPolicy("https://example.com/hook?token=x", def, time.UTC)
+	if err != nil || raw != "https://example.com/hook?token=x" || p != def {
+		t.Errorf("without parameters: %q, %+v, %v; want the URL and default policy", raw, p, err)
+	}
+}
+
+// pushRun pushes a run of n with the given messages and result.
+func pushRun(t *testing.T, n *WebhookNotifier, result string, failedIndexes []int, msgs ...Message) {
+	t.Helper()
+	sess := n.Begin("run")
+	for _, m := range msgs {
+		sess.Collect(m)
+	}
+	summary := Summary{Result: result}
+	summary.Accounts.FailedIndexes = failedIndexes
+	sess.Summarize(summary)
+	if err := sess.Push(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPolicyModes(t *testing.T) {
+	ok := Message{Text: "签到成功", Account: 1}
+	fail := Message{Text: "签到失败", Account: 1, Level: LevelError}
+	type run struct {
+		result string
+		failed []int
+		msg    Message
+		want   bool
+	}
+	tests := []struct {
+		policy string
+		runs   []run
+	}{
+		{"always", []run{
+			{"success", nil, ok, true},
+			{"success", nil, ok, true},
+		}},
+		{"on-failure", []run{
+			{"success", nil, ok, false},
+			{"failed", []int{1}, fail, true},
+			{"success", nil, ok, false},
+		}},
+		// The previous outcome is kept in the store.
+		{"on-change", []run{
+			{"success", nil, ok, true},
+			{"success", nil, ok, false},
+			{"failed", []int{1}, fail, true},
+			{"failed", []int{1}, fail, false},
+			{"failed", []int{2}, fail, true},
+			{"success", nil, ok, true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			rec := newWebhookRecorder(t)
+			n := NewWebhookNotifier([]string{rec.URL + "?policy=" + tt.policy}, Options{Store: storage.NewMemoryStore()})
+			for i, r := range tt.runs {
+				before := len(rec.Reports())
+				pushRun(t, n, r.result, r.failed, r.msg)
+				if sent := len(rec.Reports()) > before; sent != r.want {
+					t.Errorf("run %d (%s %v): sent = %v, want %v", i, r.result, r.failed, sent, r.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMinLevel(t *testing.T) {
+	rec := newWebhookRecorder(t)
+	n := NewWebhookNotifier([]string{rec.URL + "?min_level=warn", rec.URL + "?x=all"}, Options{})
+	pushRun(t, n, "failed", []int{1},
+		Message{Text: "debug", Level: LevelDebug},
+		Message{Text: "info", Account: 1},
+		Message{Text: "warn", Account: 1, Level: LevelWarn},
+		Message{Text: "error", Account: 1, Level: LevelError},
+	)
+	got := make(map[string]bool)
+	for _, r := range rec.Reports() {
+		got[strings.Join(r.texts(), ",")] = true
+	}
+	for _, want := range []string{"warn,error", "info,warn,error"} {
+		if !got[want] {
+			t.Errorf("reports = %v, want one with %s", got, want)
+		}
+	}
+}
+
+func TestQuietHoursDeferral(t *testing.T) {
+	rec := newWebhookRecorder(t)
+	store := storage.NewMemoryStore()
+	n := NewWebhookNotifier([]string{rec.URL + "?quiet_hours=23:00-07:00"}, Options{Store: store, Location: time.UTC})
+	tg := &n.targets[0]
+	key := storage.GenerateNotificationKey("deferred", tg.key)
+	at := func(day, hour int) time.Time { return time.Date(2025, 3, day, hour, 0, 0, 0, time.UTC) }
+	report := func(text string) *Report {
+		return &Report{Title: "签到", Messages: []Message{{Text: text, Account: 1}}}
+	}
+	ctx := context.Background()
+
+	// Reports during quiet hours are queued, in order.
+	for i, text := range []string{"first", "second"} {
+		if err := n.deliver(ctx, tg, report(text), at(10, 23+i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := rec.Reports(); len(got) != 0 {
+		t.Fatalf("delivered during quiet hours: %+v", got)
+	}
+	if queue, _ := n.loadDeferred(key); len(queue) != 2 {
+		t.Fatalf("queue = %+v, want two reports", queue)
+	}
+
+	// A failed flush keeps the queue for the next attempt.
+	rec.fail.Store(true)
+	if err := n.deliver(ctx, tg, nil, at(11, 8)); err == nil {
+		t.Error("failed flush reported no error")
+	}
+	if queue, _ := n.loadDeferred(key); len(queue) != 2 {
+		t.Fatalf("queue after failed flush = %+v, want two reports", queue)
+	}
+
+	// After quiet hours the queue is flushed before the new report.
+	rec.fail.Store(false)
+	if err := n.deliver(ctx, tg, report("third"), at(11, 8)); err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, r := range rec.Reports() {
+		texts = append(texts, r.texts()...)
+	}
+	if strings.Join(texts, ",") != "first,second,third" {
+		t.Errorf("delivered %v, want first, second, third", texts)
+	}
+	if _, ok, _ := store.Get(key); ok {
+		t.Error("queue kept after flush")
+	}
+}
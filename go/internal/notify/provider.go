@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// provider delivers a report to a single notification target.
+type provider interface {
+	Send(ctx context.Context, report *Report) error
+}
+
+// providerFactories maps URL schemes in NOTIFICATION_URLS to provider constructors.
+var providerFactories = map[string]func(u *url.URL) (provider, error){
+	"http":     newWebhookProvider,
+	"https":    newWebhookProvider,
+	"smtp":     newSMTPProvider,
+	"smtps":    newSMTPProvider,
+	"discord":  newDiscordProvider,
+	"slack":    newSlackProvider,
+	"teams":    newTeamsProvider,
+	"ntfy":     newNtfyProvider,
+	"gotify":   newGotifyProvider,
+	"qinglong": newQingLongProvider,
+}
+
+// hostProviders recognises chat platform webhooks given as plain https:// URLs.
+var hostProviders = []struct {
+	name    string
+	match   func(u *url.URL) bool
+	factory func(u *url.URL) (provider, error)
+}{
+	{
+		name: "discord",
+		match: func(u *url.URL) bool {
+			host := strings.ToLower(u.Hostname())
+			return (host == "discord.com" || host == "discordapp.com" || strings.HasSuffix(host, ".discord.com")) &&
+				strings.HasPrefix(u.Path, "/api/webhooks/")
+		},
+		factory: newDiscordProvider,
+	},
+	{
+		name:    "slack",
+		match:   func(u *url.URL) bool { return strings.EqualFold(u.Hostname(), "hooks.slack.com") },
+		factory: newSlackProvider,
+	},
+	{
+		name: "teams",
+		match: func(u *url.URL) bool {
+			host := strings.ToLower(u.Hostname())
+			return strings.HasSuffix(host, ".webhook.office.com") || strings.HasSuffix(host, ".logic.azure.com")
+		},
+		factory: newTeamsProvider,
+	},
+}
+
+// newProvider parses a notification URL and creates the matching provider,
+// returning it together with the provider name used to select templates.
+func newProvider(rawURL string) (provider, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid notification url: %w", err)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "https" {
+		for _, hp := range hostProviders {
+			if hp.match(u) {
+				p, err := hp.factory(u)
+				return p, hp.name, err
+			}
+		}
+	}
+	factory, ok := providerFactories[scheme]
+	if !ok {
+		return nil, scheme, fmt.Errorf("unsupported notification scheme %q", u.Scheme)
+	}
+	name := scheme
+	switch scheme {
+	case "http", "https":
+		name = "webhook"
+	case "smtps":
+		name = "smtp"
+	}
+	p, err := factory(u)
+	return p, name, err
+}
+
+// defaultTemplateFor returns the built-in template used by a provider. Chat
+// providers render the summary natively, so they only need the messages.
+func defaultTemplateFor(name string) string {
+	switch name {
+	case "discord", "slack", "teams":
+		return CompactTemplate
+	default:
+		return DefaultTemplate
+	}
+}
+
+// serverURL builds the http(s) URL of a self-hosted service from its
+// notification URL, dropping credentials and query parameters.
+func serverURL(u *url.URL, scheme, path string) string {
+	if scheme != "http" {
+		scheme = "https"
+	}
+	out := url.URL{Scheme: scheme, Host: u.Host, Path: path}
+	return out.String()
+}
+
+func parsePriority(v string, def, lo, hi int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("invalid priority %q, must be %d-%d", v, lo, hi)
+	}
+	return n, nil
+}
@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"sort"
+
+	"skland-daily-attendance-go/internal/config"
+)
+
+// Routes describes notification targets beyond the global URLs.
+type Routes struct {
+	// Accounts routes the sections of single accounts to their own targets.
+	Accounts []AccountRoute
+	// Admin targets receive the full report of every account as a digest.
+	Admin []string
+}
+
+// AccountRoute sends the section of one account to additional targets.
+type AccountRoute struct {
+	// Account is the 1-based index of the account.
+	Account int
+	URLs    []string
+	// Exclusive keeps the account out of the global targets, so only its
+	// own targets (and admin targets) see it.
+	Exclusive bool
+}
+
+func routesFromConfig(cfg *config.Config) Routes {
+	routes := Routes{Admin: cfg.AdminNotificationURLs}
+	for account, an := range cfg.AccountNotifications {
+		routes.Accounts = append(routes.Accounts, AccountRoute{
+			Account:   account,
+			URLs:      an.URLs,
+			Exclusive: an.Replace,
+		})
+	}
+	sort.Slice(routes.Accounts, func(i, j int) bool {
+		return routes.Accounts[i].Account < routes.Accounts[j].Account
+	})
+	return routes
+}
+
+// scope selects the account sections a target receives.
+type scope struct {
+	// admin receives every account and marks the report as a digest.
+	admin bool
+	// global receives every account that is not routed exclusively.
+	global bool
+	// accounts are routed to the target explicitly.
+	accounts map[int]bool
+}
+
+func (s scope) includes(account int, exclusive map[int]bool) bool {
+	return s.admin || s.accounts[account] || (s.global && !exclusive[account])
+}
+
+type recipient struct {
+	url   string
+	scope scope
+}
+
+// recipients merges the global URLs and routes into one entry per URL, in
+// order of first appearance.
+func recipients(global []string, routes Routes) []recipient {
+	var out []recipient
+	index := make(map[string]int)
+	get := func(url string) *scope {
+		i, ok := index[url]
+		if !ok {
+			i = len(out)
+			index[url] = i
+			out = append(out, recipient{url: url, scope: scope{accounts: make(map[int]bool)}})
+		}
+		return &out[i].scope
+	}
+
+	for _, u := range global {
+		get(u).global = true
+	}
+	for _, r := range routes.Accounts {
+		for _, u := range r.URLs {
+			get(u).accounts[r.Account] = true
+		}
+	}
+	for _, u := range routes.Admin {
+		get(u).admin = true
+	}
+	return out
+}
+
+// reportFor builds the report for a target from the sections in its scope,
+// or returns nil when none of the collected messages concern it.
+func (b *buffer) reportFor(t *target, exclusive map[int]bool) *Report {
+	include := func(account int) bool { return t.scope.includes(account, exclusive) }
+
+	var indexes []int
+	for account := range b.accounts {
+		if include(account) {
+			indexes = append(indexes, account)
+		}
+	}
+	if len(indexes) == 0 && len(b.general) == 0 {
+		return nil
+	}
+	sort.Ints(indexes)
+
+	report := &Report{
+		RunID:    b.runID,
+		Title:    "森空岛每日签到",
+		Messages: append([]Message(nil), b.general...),
+		Summary:  b.summary,
+	}
+	if b.summary != nil {
+		report.AccountTotal = b.summary.Accounts.Total
+	}
+	if t.scope.admin {
+		report.Title += "（汇总）"
+	}
+	for _, account := range indexes {
+		report.Messages = append(report.Messages, b.accounts[account]...)
+	}
+
+	// Recipients that do not see every account get a summary of their own
+	// accounts only.
+	if b.summary != nil && !t.scope.admin {
+		if own := b.summary.forAccounts(include); len(own.AccountResults) < len(b.summary.AccountResults) {
+			report.Summary = own
+		}
+	}
+	return report
+}
@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"skland-daily-attendance-go/internal/config"
+)
+
+func TestAccountRouting(t *testing.T) {
+	global, first, second, admin := newWebhookRecorder(t), newWebhookRecorder(t), newWebhookRecorder(t), newWebhookRecorder(t)
+	t.Setenv("TOKENS", "tok-1,tok-2,tok-3")
+	t.Setenv("NOTIFICATION_URLS", global.URL)
+	t.Setenv("ACCOUNT_1_NOTIFICATION_URLS", first.URL)
+	t.Setenv("ACCOUNT_2_NOTIFICATION_URLS", second.URL)
+	t.Setenv("ACCOUNT_2_NOTIFICATION_MODE", "replace")
+	t.Setenv("ADMIN_NOTIFICATION_URLS", admin.URL)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := New(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess := n.Begin("run")
+	sess.Collect(Message{Text: "开始签到"})
+	for _, m := range []Message{
+		{Text: "账号 1 签到成功", Account: 1},
+		{Text: "账号 2 签到失败", Account: 2, Level: LevelError},
+		{Text: "账号 3 签到成功", Account: 3},
+	} {
+		sess.Collect(m)
+	}
+	game := func(failed int) []GameSummary {
+		return []GameSummary{{GameID: 1, GameName: "明日方舟", Total: 1, Succeeded: 1 - failed, Failed: failed}}
+	}
+	sess.Summarize(Summary{
+		Result: "failed",
+		Accounts: AccountSummary{
+			Total: 3, Successful: 2, Failed: 1, FailedIndexes: []int{2},
+		},
+		Games: []GameSummary{{GameID: 1, GameName: "明日方舟", Total: 3, Succeeded: 2, Failed: 1}},
+		AccountResults: []AccountResult{
+			{Index: 1, Result: "success", Games: game(0)},
+			{Index: 2, Result: "failed", Games: game(1)},
+			{Index: 3, Result: "success", Games: game(0)},
+		},
+	})
+	if err := sess.Push(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		rec       *webhookRecorder
+		title     string
+		texts     []string
+		result    string
+		accounts  []int
+		failedIdx []int
+	}{
+		// Account 2 replaces the global targets, so only its own and the
+		// admin targets see it.
+		{"global", global, "森空岛每日签到", []string{"开始签到", "账号 1 签到成功", "账号 3 签到成功"}, "success", []int{1, 3}, nil},
+		{"account 1", first, "森空岛每日签到", []string{"开始签到", "账号 1 签到成功"}, "success", []int{1}, nil},
+		{"account 2", second, "森空岛每日签到", []string{"开始签到", "账号 2 签到失败"}, "failed", []int{2}, []int{2}},
+		{"admin", admin, "森空岛每日签到（汇总）", []string{"开始签到", "账号 1 签到成功", "账号 2 签到失败", "账号 3 签到成功"}, "failed", []int{1, 2, 3}, []int{2}},
+	}
+	for _, tt := range tests {
+		reports := tt.rec.Reports()
+		if len(reports) != 1 {
+			t.Errorf("%s: got %d reports, want 1", tt.name, len(reports))
+			continue
+		}
+		r := reports[0]
+		if r.Title != tt.title {
+			t.Errorf("%s: title = %q, want %q", tt.name, r.Title, tt.title)
+		}
+		if got := r.texts(); !reflect.DeepEqual(got, tt.texts) {
+			t.Errorf("%s: messages = %q, want %q", tt.name, got, tt.texts)
+		}
+		if r.Summary == nil {
+			t.Errorf("%s: report without summary", tt.name)
+			continue
+		}
+		var accounts []int
+		for _, a := range r.Summary.AccountResults {
+			accounts = append(accounts, a.Index)
+		}
+		if r.Summary.Result != tt.result || !reflect.DeepEqual(accounts, tt.accounts) ||
+			!reflect.DeepEqual(r.Summary.Accounts.FailedIndexes, tt.failedIdx) {
+			t.Errorf("%s: summary %s of accounts %v failing %v, want %s of %v failing %v", tt.name,
+				r.Summary.Result, accounts, r.Summary.Accounts.FailedIndexes, tt.result, tt.accounts, tt.failedIdx)
+		}
+		if games := r.Summary.Games; len(games) != 1 || games[0].Total != len(tt.accounts) {
+			t.Errorf("%s: games = %+v, want the characters of %d accounts", tt.name, games, len(tt.accounts))
+		}
+	}
+}
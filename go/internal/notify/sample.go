@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TargetResult is the outcome of a test delivery to a single target.
+type TargetResult struct {
+	// Target is a description of the target that is safe to print.
+	Target   string
+	Provider string
+	Err      error
+}
+
+// SendTest delivers a sample report to every target, bypassing policies and
+// quiet hours, so that the configuration can be checked without a run.
+func (n *WebhookNotifier) SendTest(ctx context.Context) []TargetResult {
+	results := make([]TargetResult, len(n.targets))
+	var wg sync.WaitGroup
+	for i := range n.targets {
+		t := &n.targets[i]
+		results[i] = TargetResult{Target: t.label, Provider: t.name, Err: t.err}
+		if t.err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, t *target) {
+			defer wg.Done()
+			r, err := n.prepare(t, sampleReport())
+			if err == nil {
+				err = t.send(ctx, r)
+			}
+			results[i].Err = redactURLError(err)
+		}(i, t)
+	}
+	wg.Wait()
+	return results
+}
+
+// sampleReport returns a report resembling a real run of one account.
+func sampleReport() *Report {
+	now := time.Now()
+	msgs := []Message{
+		{Text: "这是一条测试通知，收到即表示推送配置正确", Stage: StageRun, Time: now},
+		{
+			Text:         "明日方舟-测试角色 签到成功",
+			Stage:        StageAttendance,
+			Account:      1,
+			AccountLabel: "测试账号",
+			GameID:       1,
+			GameName:     "明日方舟",
+			Character:    "明日方舟-测试角色",
+			Rewards:      []string{"龙门币x500"},
+			Time:         now,
+		},
+	}
+	return &Report{
+		RunID:        "test",
+		Title:        "森空岛每日签到（测试）",
+		Messages:     msgs,
+		AccountTotal: 1,
+		Summary: &Summary{
+			Result:     "success",
+			StartedAt:  now.Add(-time.Second),
+			FinishedAt: now,
+			Accounts:   AccountSummary{Total: 1, Successful: 1},
+			Games:      []GameSummary{{GameID: 1, GameName: "明日方舟", Total: 1, Succeeded: 1}},
+			AccountResults: []AccountResult{{
+				Index:  1,
+				Label:  "测试账号",
+				Result: "success",
+				Games:  []GameSummary{{GameID: 1, GameName: "明日方舟", Total: 1, Succeeded: 1}},
+			}},
+		},
+	}
+}
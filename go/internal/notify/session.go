@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"skland-daily-attendance-go/internal/tracing"
+)
+
+// buffer holds the messages collected during one run: run-level messages
+// and the messages of each account, so that every recipient gets only its
+// own sections.
+type buffer struct {
+	runID    string
+	general  []Message
+	accounts map[int][]Message
+	summary  *Summary
+}
+
+func newBuffer(runID string) *buffer {
+	return &buffer{
+		runID:    runID,
+		accounts: make(map[int][]Message),
+	}
+}
+
+func (b *buffer) empty() bool {
+	return len(b.general) == 0 && len(b.accounts) == 0
+}
+
+// session is the Session of a WebhookNotifier.
+type session struct {
+	notifier *WebhookNotifier
+
+	mu  sync.Mutex
+	buf *buffer
+}
+
+// Collect appends a message to the buffer of its account.
+func (s *session) Collect(msg Message) {
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if msg.Account == 0 {
+		s.buf.general = append(s.buf.general, msg)
+		return
+	}
+	s.buf.accounts[msg.Account] = append(s.buf.accounts[msg.Account], msg)
+}
+
+// Summarize attaches the execution summary to the report.
+func (s *session) Summarize(summary Summary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf.summary = &summary
+}
+
+// Push delivers the collected report to every target and resets the
+// session, so that a later push never repeats these messages. Transient
+// failures are retried; when any target still fails, a *DeliveryError
+// describing each failed target is returned after all targets were tried.
+func (s *session) Push(ctx context.Context) (err error) {
+	s.mu.Lock()
+	buf := s.buf
+	s.buf = newBuffer(buf.runID)
+	s.mu.Unlock()
+
+	ctx, span := tracing.Start(ctx, "notify.push")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	if buf.empty() {
+		return s.notifier.deliverAll(ctx, nil)
+	}
+	return s.notifier.deliverAll(ctx, buf)
+}
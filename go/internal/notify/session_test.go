@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// webhookRecorder is a webhook target recording the reports delivered to it.
+type webhookRecorder struct {
+	*httptest.Server
+
+	// fail makes the target reject deliveries.
+	fail atomic.Bool
+
+	mu      sync.Mutex
+	reports []webhookPayload
+}
+
+// webhookPayload is the part of the webhook payload checked by tests.
+type webhookPayload struct {
+	Title    string `json:"title"`
+	Text     string `json:"text"`
+	Messages []struct {
+		Text    string `json:"text"`
+		Level   Level  `json:"level"`
+		Account int    `json:"account"`
+	} `json:"messages"`
+	Summary *Summary `json:"summary"`
+}
+
+func newWebhookRecorder(t *testing.T) *webhookRecorder {
+	t.Helper()
+	rec := &webhookRecorder{}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rec.fail.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var p webhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("decode webhook payload: %v", err)
+		}
+		rec.mu.Lock()
+		rec.reports = append(rec.reports, p)
+		rec.mu.Unlock()
+	}))
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+// Reports returns the delivered reports in order.
+func (rec *webhookRecorder) Reports() []webhookPayload {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]webhookPayload(nil), rec.reports...)
+}
+
+// texts returns the texts of the messages of p.
+func (p webhookPayload) texts() []string {
+	var out []string
+	for _, m := range p.Messages {
+		out = append(out, m.Text)
+	}
+	return out
+}
+
+// TestConcurrentSessions runs many sessions of one notifier at once, each
+// collecting from several goroutines, and checks that every push carries
+// only the messages of its own run. Run it with -race.
+func TestConcurrentSessions(t *testing.T) {
+	rec := newWebhookRecorder(t)
+	n := NewWebhookNotifier([]string{rec.URL}, Options{})
+
+	const runs, collectors, perCollector = 16, 4, 5
+	var wg sync.WaitGroup
+	for run := 0; run < runs; run++ {
+		wg.Add(1)
+		go func(run int) {
+			defer wg.Done()
+			sess := n.Begin(fmt.Sprintf("run-%d", run))
+			var collect sync.WaitGroup
+			for c := 0; c < collectors; c++ {
+				collect.Add(1)
+				go func(c int) {
+					defer collect.Done()
+					for i := 0; i < perCollector; i++ {
+						sess.Collect(Message{Text: fmt.Sprintf("run-%d/%d/%d", run, c, i), Account: c})
+					}
+				}(c)
+			}
+			collect.Wait()
+			sess.Summarize(Summary{Result: "success"})
+			if err := sess.Push(context.Background()); err != nil {
+				t.Errorf("push run %d: %v", run, err)
+			}
+			// The session was reset: a second push sends nothing.
+			if err := sess.Push(context.Background()); err != nil {
+				t.Errorf("second push of run %d: %v", run, err)
+			}
+		}(run)
+	}
+	wg.Wait()
+
+	reports := rec.Reports()
+	if len(reports) != runs {
+		t.Fatalf("got %d reports, want %d", len(reports), runs)
+	}
+	seen := make(map[string]bool)
+	for _, r := range reports {
+		texts := r.texts()
+		if len(texts) != collectors*perCollector {
+			t.Errorf("report has %d messages, want %d: %v", len(texts), collectors*perCollector, texts)
+			continue
+		}
+		run, _, _ := strings.Cut(texts[0], "/")
+		if seen[run] {
+			t.Errorf("%s pushed twice", run)
+		}
+		seen[run] = true
+		for _, text := range texts {
+			if !strings.HasPrefix(text, run+"/") {
+				t.Errorf("report of %s contains %q", run, text)
+			}
+		}
+	}
+}
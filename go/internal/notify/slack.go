@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Slack Block Kit limits, see https://api.slack.com/reference/block-kit/blocks
+const (
+	slackSectionTextLimit = 3000
+	slackFieldTextLimit   = 2000
+	slackMaxFields        = 10
+	slackMaxBlocks        = 50
+)
+
+// slackProvider posts the report to a Slack incoming webhook using Block Kit.
+//
+// URL format: slack://hooks.slack.com/services/<T>/<B>/<X>, or the plain
+// https:// webhook URL copied from Slack.
+type slackProvider struct {
+	url    string
+	client *http.Client
+}
+
+func newSlackProvider(u *url.URL) (provider, error) {
+	return &slackProvider{
+		url: httpsURL(u),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type   string      `json:"type"`
+	Text   *slackText  `json:"text,omitempty"`
+	Fields []slackText `json:"fields,omitempty"`
+}
+
+// Send posts the report to the Slack webhook.
+func (p *slackProvider) Send(ctx context.Context, report *Report) error {
+	title := report.Title
+	if report.HasError() {
+		title = "❌ " + title
+	} else {
+		title = "✅ " + title
+	}
+
+	blocks := []slackBlock{{
+		Type: "header",
+		Text: &slackText{Type: "plain_text", Text: truncate(title, 150)},
+	}}
+
+	if s := report.Summary; s != nil {
+		fields := []slackText{{
+			Type: "mrkdwn",
+			Text: truncate("*账号统计*\n"+s.accountLine(), slackFieldTextLimit),
+		}}
+		for _, g := range s.Games {
+			if len(fields) >= slackMaxFields {
+				break
+			}
+			fields = append(fields, slackText{
+				Type: "mrkdwn",
+				Text: truncate(fmt.Sprintf("*【%s】角色统计*\n%s", g.Name(), g.gameLine()), slackFieldTextLimit),
+			})
+		}
+		blocks = append(blocks, slackBlock{Type: "section", Fields: fields}, slackBlock{Type: "divider"})
+	}
+
+	for _, chunk := range chunkLines(report.Text(), slackSectionTextLimit-8) {
+		if len(blocks) >= slackMaxBlocks {
+			break
+		}
+		blocks = append(blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: "```" + chunk + "```"},
+		})
+	}
+
+	payload := map[string]any{
+		"text":   title,
+		"blocks": blocks,
+	}
+	return postJSON(ctx, p.client, p.url, nil, payload)
+}
+
+// chunkLines groups the lines of text into newline separated chunks of at
+// most limit runes each, escaping Slack's control characters.
+func chunkLines(text string, limit int) []string {
+	var (
+		chunks []string
+		cur    strings.Builder
+		n      int
+	)
+	for _, line := range strings.Split(text, "\n") {
+		line = truncate(escapeSlack(line), limit)
+		size := len([]rune(line)) + 1
+		if n > 0 && n+size > limit {
+			chunks = append(chunks, cur.String())
+			cur.Reset()
+			n = 0
+		}
+		if n > 0 {
+			cur.WriteByte('\n')
+		}
+		cur.WriteString(line)
+		n += size
+	}
+	if n > 0 {
+		chunks = append(chunks, cur.String())
+	}
+	return chunks
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func escapeSlack(s string) string {
+	return slackEscaper.Replace(s)
+}
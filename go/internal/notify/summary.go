@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Summary is the execution summary of a run. It is attached to the report so
+// that providers can render it in a richer form than plain text lines.
+type Summary struct {
+	Result     string         `json:"result"`
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt time.Time      `json:"finishedAt"`
+	Accounts   AccountSummary `json:"accounts"`
+	Games      []GameSummary  `json:"games"`
+	// AccountResults holds the outcome of every processed account, used to
+	// narrow the summary down to the accounts a recipient is routed.
+	AccountResults []AccountResult `json:"accountResults,omitempty"`
+}
+
+// AccountResult is the outcome of a single account.
+type AccountResult struct {
+	Index  int           `json:"index"`
+	Label  string        `json:"label"`
+	Result string        `json:"result"` // "success", "skipped" or "failed"
+	Games  []GameSummary `json:"games,omitempty"`
+}
+
+// AccountSummary holds per-account counters.
+type AccountSummary struct {
+	Total         int   `json:"total"`
+	Successful    int   `json:"successful"`
+	Skipped       int   `json:"skipped"`
+	Failed        int   `json:"failed"`
+	FailedIndexes []int `json:"failedIndexes"`
+}
+
+// GameSummary holds per-game character counters.
+type GameSummary struct {
+	GameID          int    `json:"gameId"`
+	GameName        string `json:"gameName,omitempty"`
+	Total           int    `json:"total"`
+	Succeeded       int    `json:"succeeded"`
+	AlreadyAttended int    `json:"alreadyAttended"`
+	Failed          int    `json:"failed"`
+}
+
+// forAccounts returns the summary restricted to the accounts accepted by
+// include, recomputing the counters from the per-account results.
+func (s *Summary) forAccounts(include func(idx int) bool) *Summary {
+	out := &Summary{
+		Result:     "success",
+		StartedAt:  s.StartedAt,
+		FinishedAt: s.FinishedAt,
+	}
+	games := make(map[int]*GameSummary)
+	var order []int
+	for _, a := range s.AccountResults {
+		if !include(a.Index) {
+			continue
+		}
+		out.AccountResults = append(out.AccountResults, a)
+		out.Accounts.Total++
+		switch a.Result {
+		case "success":
+			out.Accounts.Successful++
+		case "skipped":
+			out.Accounts.Skipped++
+		default:
+			out.Accounts.Failed++
+			out.Accounts.FailedIndexes = append(out.Accounts.FailedIndexes, a.Index)
+			out.Result = "failed"
+		}
+		for _, g := range a.Games {
+			agg, ok := games[g.GameID]
+			if !ok {
+				agg = &GameSummary{GameID: g.GameID, GameName: g.GameName}
+				games[g.GameID] = agg
+				order = append(order, g.GameID)
+			}
+			agg.Total += g.Total
+			agg.Succeeded += g.Succeeded
+			agg.AlreadyAttended += g.AlreadyAttended
+			agg.Failed += g.Failed
+		}
+	}
+	sort.Ints(order)
+	for _, id := range order {
+		out.Games = append(out.Games, *games[id])
+	}
+	return out
+}
+
+// accountLine renders the account counters on a single line.
+func (s *Summary) accountLine() string {
+	line := fmt.Sprintf("总数 %d / 成功 %d / 跳过 %d / 失败 %d",
+		s.Accounts.Total, s.Accounts.Successful, s.Accounts.Skipped, s.Accounts.Failed)
+	if len(s.Accounts.FailedIndexes) > 0 {
+		line += fmt.Sprintf(" (账号 #%v)", s.Accounts.FailedIndexes)
+	}
+	return line
+}
+
+// Name returns the game name, falling back to its id.
+func (g GameSummary) Name() string {
+	if g.GameName != "" {
+		return g.GameName
+	}
+	return fmt.Sprint(g.GameID)
+}
+
+// gameLine renders the character counters of a game on a single line.
+func (g GameSummary) gameLine() string {
+	return fmt.Sprintf("总数 %d / 成功 %d / 已签到 %d / 失败 %d",
+		g.Total, g.Succeeded, g.AlreadyAttended, g.Failed)
+}
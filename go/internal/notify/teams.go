@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// teamsTextLimit is the byte budget of the report text, keeping the Adaptive
+// Card comfortably below the 28 KB payload limit of Teams incoming webhooks
+// and leaving room for the JSON envelope and escaping.
+const teamsTextLimit = 20000
+
+// teamsProvider posts the report to a Microsoft Teams incoming webhook (or a
+// Power Automate workflow webhook) as an Adaptive Card.
+//
+// URL format: teams://<tenant>.webhook.office.com/webhookb2/..., or the plain
+// https:// webhook URL copied from Teams.
+type teamsProvider struct {
+	url    string
+	client *http.Client
+}
+
+func newTeamsProvider(u *url.URL) (provider, error) {
+	return &teamsProvider{
+		url: httpsURL(u),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+// Send posts the report to the Teams webhook.
+func (p *teamsProvider) Send(ctx context.Context, report *Report) error {
+	titleColor := "good"
+	if report.HasError() {
+		titleColor = "attention"
+	}
+
+	body := []map[string]any{{
+		"type":   "TextBlock",
+		"text":   report.Title,
+		"size":   "large",
+		"weight": "bolder",
+		"color":  titleColor,
+	}}
+
+	if s := report.Summary; s != nil {
+		facts := []map[string]string{{"title": "账号统计", "value": s.accountLine()}}
+		for _, g := range s.Games {
+			facts = append(facts, map[string]string{
+				"title": fmt.Sprintf("【%s】角色统计", g.Name()),
+				"value": g.gameLine(),
+			})
+		}
+		body = append(body, map[string]any{"type": "FactSet", "facts": facts})
+	}
+
+	// Adaptive Card markdown needs blank lines to break paragraphs.
+	body = append(body, map[string]any{
+		"type":     "TextBlock",
+		"text":     truncateBytes(strings.ReplaceAll(report.Text(), "\n", "\n\n"), teamsTextLimit),
+		"wrap":     true,
+		"fontType": "monospace",
+	})
+
+	payload := map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]any{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body":    body,
+				"msteams": map[string]string{"width": "Full"},
+			},
+		}},
+	}
+	return postJSON(ctx, p.client, p.url, nil, payload)
+}
@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTeamsPayloadFitsWebhookLimit(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	// Chinese lines take 3 bytes per rune and double their newlines.
+	report := sampleReport()
+	report.text = strings.Repeat("明日方舟-测试角色 签到成功，获得龙门币x500\n", 2000)
+	p := &teamsProvider{url: srv.URL, client: srv.Client()}
+	if err := p.Send(context.Background(), report); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if len(body) > 28<<10 {
+		t.Errorf("payload is %d bytes, over the 28 KB webhook limit", len(body))
+	}
+	var payload struct {
+		Attachments []struct {
+			Content struct {
+				Body []struct {
+					Text string `json:"text"`
+				} `json:"body"`
+			} `json:"content"`
+		} `json:"attachments"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	blocks := payload.Attachments[0].Content.Body
+	text := blocks[len(blocks)-1].Text
+	if !utf8.ValidString(text) || !strings.HasSuffix(text, "…") {
+		t.Errorf("text was not cut on a rune boundary with an ellipsis: %q", text[len(text)-20:])
+	}
+	if len(text) > teamsTextLimit {
+		t.Errorf("text is %d bytes, want at most %d", len(text), teamsTextLimit)
+	}
+}
@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Built-in template names. Providers that render the summary natively (chat
+// cards, embeds) use the compact template so the summary is not shown twice.
+const (
+	DefaultTemplate = "default"
+	CompactTemplate = "compact"
+)
+
+const builtinTemplates = `
+{{- define "messages" -}}
+{{- range .General}}{{.Text}}
+{{end -}}
+{{- range .Accounts}}--- 账号 {{.Index}}/{{.Total}} ---
+{{range .Messages}}{{if .Rewards}}{{.Text}}（奖励: {{rewards .Rewards}}）{{else}}{{.Text}}{{end}}
+{{end -}}
+{{- end -}}
+{{- end -}}
+
+{{- define "summary" -}}
+{{- with .Summary -}}
+========== 执行摘要 ==========
+账号统计:
+  • 总数: {{.Accounts.Total}}
+  • 成功: {{.Accounts.Successful}}
+  • 跳过: {{.Accounts.Skipped}}
+{{if .Accounts.Failed}}  • 失败: {{.Accounts.Failed}} (账号 #{{.Accounts.FailedIndexes}})
+{{end -}}
+{{- range .Games}}【{{.Name}}】角色统计:
+  • 总数: {{.Total}}
+  • 本次签到成功: {{.Succeeded}}
+  • 今天已签到: {{.AlreadyAttended}}
+{{if .Failed}}  • 签到失败: {{.Failed}}
+{{end -}}
+{{- end -}}
+{{- end -}}
+{{- end -}}
+
+{{- define "default"}}{{template "messages" .}}{{template "summary" .}}{{end -}}
+{{- define "compact"}}{{template "messages" .}}{{end -}}
+`
+
+// templateFuncs are the helpers available to built-in and user templates.
+var templateFuncs = template.FuncMap{
+	// status renders a success/failure emoji from a bool (true = error) or a
+	// run result string ("success" / "failed").
+	"status": func(v any) string {
+		failed := false
+		switch x := v.(type) {
+		case bool:
+			failed = x
+		case string:
+			failed = x != "success"
+		}
+		if failed {
+			return "❌"
+		}
+		return "✅"
+	},
+	// duration renders a duration rounded to seconds in Chinese units.
+	"duration": formatDuration,
+	// rewards joins reward names, rendering an empty list as "无".
+	"rewards": func(list []string) string {
+		if len(list) == 0 {
+			return "无"
+		}
+		return strings.Join(list, "、")
+	},
+	"join": strings.Join,
+}
+
+// Templates holds the parsed built-in templates plus user overrides.
+type Templates struct {
+	base      *template.Template
+	overrides map[string]*template.Template
+}
+
+// ParseTemplates parses user supplied templates keyed by provider name
+// (e.g. "discord", "smtp"); the empty key overrides the template of every
+// provider that has no specific one. Each value is either inline template
+// text or a path to a template file.
+func ParseTemplates(sources map[string]string) (*Templates, error) {
+	base := template.Must(template.New("builtin").Funcs(templateFuncs).Parse(builtinTemplates))
+	t := &Templates{
+		base:      base,
+		overrides: make(map[string]*template.Template),
+	}
+
+	for name, src := range sources {
+		text, err := loadTemplateSource(src)
+		if err != nil {
+			return nil, fmt.Errorf("notification template %q: %w", name, err)
+		}
+		clone, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := clone.New("user").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("notification template %q: %w", name, err)
+		}
+		t.overrides[strings.ToLower(name)] = tmpl
+	}
+	return t, nil
+}
+
+// loadTemplateSource treats values containing template actions as inline
+// templates and everything else as a file path.
+func loadTemplateSource(src string) (string, error) {
+	if strings.Contains(src, "{{") {
+		return src, nil
+	}
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// render executes the template selected for the given provider.
+func (t *Templates) render(providerName, fallback string, report *Report) (string, error) {
+	tmpl, ok := t.overrides[providerName]
+	if !ok {
+		tmpl, ok = t.overrides[""]
+	}
+	if !ok {
+		tmpl = t.base.Lookup(fallback)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, report); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < time.Minute {
+		return fmt.Sprintf("%d秒", int(d.Seconds()))
+	}
+	m := int(d.Minutes())
+	s := int(d.Seconds()) % 60
+	if s == 0 {
+		return fmt.Sprintf("%d分", m)
+	}
+	return fmt.Sprintf("%d分%d秒", m, s)
+}
@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// webhookProvider posts the report as a generic JSON payload.
+type webhookProvider struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookProvider(u *url.URL) (provider, error) {
+	return &webhookProvider{
+		url: u.String(),
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+// Send posts the report to the webhook URL.
+func (p *webhookProvider) Send(ctx context.Context, report *Report) error {
+	type payloadMessage struct {
+		Text         string    `json:"text"`
+		IsError      bool      `json:"isError"`
+		Level        Level     `json:"level"`
+		Time         time.Time `json:"time"`
+		Stage        Stage     `json:"stage,omitempty"`
+		Account      int       `json:"account,omitempty"`
+		AccountLabel string    `json:"accountLabel,omitempty"`
+		GameID       int       `json:"gameId,omitempty"`
+		GameName     string    `json:"gameName,omitempty"`
+		Character    string    `json:"character,omitempty"`
+		Rewards      []string  `json:"rewards,omitempty"`
+		ErrorKind    ErrorKind `json:"errorKind,omitempty"`
+	}
+	var payload struct {
+		Title    string           `json:"title"`
+		Text     string           `json:"text"`
+		Messages []payloadMessage `json:"messages"`
+		Summary  *Summary         `json:"summary,omitempty"`
+	}
+	payload.Title = report.Title
+	payload.Text = report.Text()
+	payload.Summary = report.Summary
+	for _, m := range report.Messages {
+		payload.Messages = append(payload.Messages, payloadMessage{
+			Text:         m.Text,
+			IsError:      m.IsError(),
+			Level:        m.Level,
+			Time:         m.Time,
+			Stage:        m.Stage,
+			Account:      m.Account,
+			AccountLabel: m.AccountLabel,
+			GameID:       m.GameID,
+			GameName:     m.GameName,
+			Character:    m.Character,
+			Rewards:      m.Rewards,
+			ErrorKind:    m.ErrorKind,
+		})
+	}
+
+	return postJSON(ctx, p.client, p.url, nil, payload)
+}
@@ -0,0 +1,202 @@
+// Package qinglong is a client for the OpenAPI of the QingLong panel, used
+// to send notifications through the panel and to update its environment
+// variables. Applications are created under 系统设置 → 应用设置 and need the
+// 环境变量 and 系统信息 scopes.
+package qinglong
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client calls the QingLong OpenAPI with application credentials.
+type Client struct {
+	baseURL      string
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewClient creates a client for the panel at baseURL, e.g.
+// http://127.0.0.1:5700.
+func NewClient(baseURL, clientID, clientSecret string) *Client {
+	return &Client{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		httpClient:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Env is a panel environment variable. Panels before 2.11 identify
+// variables by the string _id instead of the numeric id.
+type Env struct {
+	ID       int    `json:"id,omitempty"`
+	LegacyID string `json:"_id,omitempty"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Remarks  string `json:"remarks,omitempty"`
+}
+
+// Error is a failure reported by the panel.
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("qinglong: code %d: %s", e.Code, e.Message)
+}
+
+// Notify sends a message through the notification channel configured in
+// the panel.
+func (c *Client) Notify(ctx context.Context, title, content string) error {
+	return c.call(ctx, http.MethodPut, "/open/system/notify", map[string]string{
+		"title":   title,
+		"content": content,
+	}, nil)
+}
+
+// Envs returns the environment variables whose name or value contains
+// search.
+func (c *Client) Envs(ctx context.Context, search string) ([]Env, error) {
+	var envs []Env
+	path := "/open/envs?searchValue=" + url.QueryEscape(search)
+	if err := c.call(ctx, http.MethodGet, path, nil, &envs); err != nil {
+		return nil, err
+	}
+	return envs, nil
+}
+
+// UpdateEnv replaces the name, value and remarks of an existing variable.
+func (c *Client) UpdateEnv(ctx context.Context, env Env) error {
+	return c.call(ctx, http.MethodPut, "/open/envs", env, nil)
+}
+
+// call sends an authenticated request and decodes the data of the
+// {code, message, data} envelope into v, which may be nil.
+func (c *Client) call(ctx context.Context, method, path string, body, v any) error {
+	token, err := c.authToken(ctx)
+	if err != nil {
+		return err
+	}
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.do(req, v)
+}
+
+// authToken returns the cached access token, requesting a new one when it
+// is missing or about to expire.
+func (c *Client) authToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+	if c.clientID == "" || c.clientSecret == "" {
+		return "", errors.New("qinglong: missing client id or secret")
+	}
+	q := url.Values{}
+	q.Set("client_id", c.clientID)
+	q.Set("client_secret", c.clientSecret)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/open/auth/token?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	var data struct {
+		Token      string `json:"token"`
+		Expiration int64  `json:"expiration"`
+	}
+	if err := c.do(req, &data); err != nil {
+		return "", fmt.Errorf("qinglong: get token: %w", err)
+	}
+	if data.Token == "" {
+		return "", errors.New("qinglong: empty token")
+	}
+	c.token = data.Token
+	// Renew a minute early; without an expiration keep it for an hour.
+	c.expires = time.Now().Add(time.Hour)
+	if data.Expiration > 0 {
+		c.expires = time.Unix(data.Expiration, 0).Add(-time.Minute)
+	}
+	return c.token, nil
+}
+
+func (c *Client) do(req *http.Request, v any) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		// Keep the client secret of auth requests out of errors.
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("%s %s: %w", req.Method, req.URL.Path, urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL.Path, resp.Status)
+	}
+	if body.Code != http.StatusOK {
+		return &Error{Code: body.Code, Message: body.Message}
+	}
+	if v == nil || len(body.Data) == 0 || string(body.Data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(body.Data, v)
+}
+
+// ReplaceToken replaces the token old with new in a token list such as
+// the value of SKLAND_TOKENS, keeping its separators and the other tokens
+// as they are.
+func ReplaceToken(list, old, new string) (string, bool) {
+	start := 0
+	for i := 0; i <= len(list); i++ {
+		if i < len(list) && !isTokenSeparator(list[i]) {
+			continue
+		}
+		field := list[start:i]
+		if strings.TrimSpace(field) == old && old != "" {
+			at := start + strings.Index(field, old)
+			return list[:at] + new + list[at+len(old):], true
+		}
+		start = i + 1
+	}
+	return list, false
+}
+
+func isTokenSeparator(b byte) bool {
+	return b == '&' || b == '\n' || b == '\r' || b == ','
+}
@@ -0,0 +1,246 @@
+package qinglong
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// panel is a stub of the QingLong OpenAPI.
+type panel struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// expiration is returned with new tokens; 0 omits it.
+	expiration int64
+	tokens     int
+	envs       []Env
+	notified   []map[string]string
+}
+
+func newPanel(t *testing.T) *panel {
+	t.Helper()
+	p := &panel{}
+	p.Server = httptest.NewServer(http.HandlerFunc(p.serve))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *panel) serve(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if r.URL.Path == "/open/auth/token" {
+		q := r.URL.Query()
+		if r.Method != http.MethodGet || q.Get("client_id") != "id" || q.Get("client_secret") != "secret" {
+			reply(w, 400, "invalid client", nil)
+			return
+		}
+		p.tokens++
+		data := map[string]any{"token": "tok" + strconv.Itoa(p.tokens), "token_type": "Bearer"}
+		if p.expiration != 0 {
+			data["expiration"] = p.expiration
+		}
+		reply(w, 200, "", data)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer tok"+strconv.Itoa(p.tokens) {
+		reply(w, 401, "UnauthorizedError", nil)
+		return
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET /open/envs":
+		search := r.URL.Query().Get("searchValue")
+		var out []Env
+		for _, env := range p.envs {
+			if strings.Contains(env.Name, search) || strings.Contains(env.Value, search) {
+				out = append(out, env)
+			}
+		}
+		reply(w, 200, "", out)
+	case "PUT /open/envs":
+		var env Env
+		if err := json.NewDecoder(r.Body).Decode(&env); err != nil {
+			reply(w, 400, err.Error(), nil)
+			return
+		}
+		for i := range p.envs {
+			if p.envs[i].ID == env.ID {
+				p.envs[i] = env
+				reply(w, 200, "", env)
+				return
+			}
+		}
+		reply(w, 400, "环境变量不存在", nil)
+	case "PUT /open/system/notify":
+		var msg map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			reply(w, 400, err.Error(), nil)
+			return
+		}
+		p.notified = append(p.notified, msg)
+		reply(w, 200, "", nil)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func reply(w http.ResponseWriter, code int, message string, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"code": code, "message": message, "data": data})
+}
+
+func (p *panel) tokenRequests() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.tokens
+}
+
+func TestClientNotify(t *testing.T) {
+	p := newPanel(t)
+	c := NewClient(p.URL+"/", "id", "secret")
+	if err := c.Notify(context.Background(), "森空岛每日签到", "签到成功"); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.notified) != 1 || p.notified[0]["title"] != "森空岛每日签到" || p.notified[0]["content"] != "签到成功" {
+		t.Errorf("notified = %v", p.notified)
+	}
+}
+
+func TestClientEnvs(t *testing.T) {
+	p := newPanel(t)
+	p.envs = []Env{
+		{ID: 1, Name: "SKLAND_TOKENS", Value: "a&b", Remarks: "森空岛"},
+		{ID: 2, Name: "OTHER", Value: "x"},
+	}
+	c := NewClient(p.URL, "id", "secret")
+	ctx := context.Background()
+
+	envs, err := c.Envs(ctx, "SKLAND")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envs) != 1 || envs[0].ID != 1 || envs[0].Value != "a&b" {
+		t.Fatalf("Envs = %+v", envs)
+	}
+	env := envs[0]
+	env.Value = "a&c"
+	if err := c.UpdateEnv(ctx, env); err != nil {
+		t.Fatal(err)
+	}
+	if p.envs[0].Value != "a&c" || p.envs[0].Remarks != "森空岛" {
+		t.Errorf("env after update = %+v", p.envs[0])
+	}
+
+	var qlErr *Error
+	err = c.UpdateEnv(ctx, Env{ID: 9, Name: "MISSING"})
+	if !errors.As(err, &qlErr) || qlErr.Code != 400 {
+		t.Errorf("UpdateEnv of a missing env = %v, want a panel error", err)
+	}
+}
+
+func TestClientTokenCache(t *testing.T) {
+	p := newPanel(t)
+	p.expiration = time.Now().Add(7 * 24 * time.Hour).Unix()
+	c := NewClient(p.URL, "id", "secret")
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Envs(ctx, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := p.tokenRequests(); n != 1 {
+		t.Errorf("requested %d tokens for three calls, want 1", n)
+	}
+
+	// A token expiring within a minute is renewed before use.
+	p.mu.Lock()
+	p.expiration = time.Now().Add(30 * time.Second).Unix()
+	p.mu.Unlock()
+	c.mu.Lock()
+	c.expires = time.Now().Add(-time.Second)
+	c.mu.Unlock()
+	for i := 0; i < 2; i++ {
+		if _, err := c.Envs(ctx, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := p.tokenRequests(); n != 3 {
+		t.Errorf("requested %d tokens, want a new one for each call near expiry (3)", n)
+	}
+
+	// Without an expiration the token is kept for an hour.
+	p.mu.Lock()
+	p.expiration = 0
+	p.mu.Unlock()
+	c.mu.Lock()
+	c.expires = time.Time{}
+	c.mu.Unlock()
+	if _, err := c.Envs(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	expires := c.expires
+	c.mu.Unlock()
+	if d := time.Until(expires); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("token without expiration kept for %s, want an hour", d)
+	}
+}
+
+func TestClientAuthErrors(t *testing.T) {
+	p := newPanel(t)
+	err := NewClient(p.URL, "id", "wrong").Notify(context.Background(), "t", "c")
+	var qlErr *Error
+	if !errors.As(err, &qlErr) || qlErr.Code != 400 {
+		t.Errorf("Notify with a wrong secret = %v, want a panel error", err)
+	}
+	if strings.Contains(err.Error(), "wrong") {
+		t.Errorf("error %q leaks the client secret", err)
+	}
+
+	if err := NewClient(p.URL, "", "").Notify(context.Background(), "t", "c"); err == nil {
+		t.Error("Notify without credentials succeeded")
+	}
+
+	// Transport errors name the endpoint but not the secret in the query.
+	p.Close()
+	err = NewClient(p.URL, "id", "secret").Notify(context.Background(), "t", "c")
+	if err == nil || strings.Contains(err.Error(), "secret") || !strings.Contains(err.Error(), "/open/auth/token") {
+		t.Errorf("Notify to a closed panel = %v", err)
+	}
+}
+
+func TestReplaceToken(t *testing.T) {
+	tests := []struct {
+		name, list, old, new string
+		want                 string
+		ok                   bool
+	}{
+		{name: "ampersand", list: "aaa&bbb&ccc", old: "bbb", new: "NEW", want: "aaa&NEW&ccc", ok: true},
+		{name: "newline", list: "aaa\nbbb\nccc\n", old: "ccc", new: "NEW", want: "aaa\nbbb\nNEW\n", ok: true},
+		{name: "crlf", list: "aaa\r\nbbb", old: "aaa", new: "NEW", want: "NEW\r\nbbb", ok: true},
+		{name: "comma with spaces", list: "aaa, bbb ,ccc", old: "bbb", new: "NEW", want: "aaa, NEW ,ccc", ok: true},
+		{name: "mixed", list: "aaa&bbb\nccc,ddd", old: "ccc", new: "NEW", want: "aaa&bbb\nNEW,ddd", ok: true},
+		{name: "single", list: "aaa", old: "aaa", new: "NEW", want: "NEW", ok: true},
+		{name: "substring of another token", list: "xbbbx&bbb", old: "bbb", new: "NEW", want: "xbbbx&NEW", ok: true},
+		{name: "missing", list: "aaa&bbb", old: "ccc", new: "NEW", want: "aaa&bbb"},
+		{name: "empty old", list: "aaa&&bbb", old: "", new: "NEW", want: "aaa&&bbb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ReplaceToken(tt.list, tt.old, tt.new)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("ReplaceToken(%q, %q) = %q, %v, want %q, %v", tt.list, tt.old, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
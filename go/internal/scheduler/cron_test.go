@@ -0,0 +1,173 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"skland-daily-attendance-go/internal/storage"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		spec, want string
+	}{
+		{"", "expected 5 fields"},
+		{"0 8 * *", "expected 5 fields"},
+		{"0 8 * * * *", "expected 5 fields"},
+		{"60 8 * * *", "minute: value 60 out of range 0-59"},
+		{"0 24 * * *", "hour: value 24 out of range 0-23"},
+		{"0 8 0 * *", "day of month: value 0 out of range 1-31"},
+		{"0 8 * 13 *", "month: value 13 out of range 1-12"},
+		{"0 8 * * 8", "day of week: value 8 out of range 0-7"},
+		{"0 8 * * funday", `day of week: invalid value "funday"`},
+		{"0 8-6 * * *", `hour: invalid range "8-6"`},
+		{"*/0 8 * * *", `minute: invalid step "0"`},
+		{"*/x 8 * * *", `minute: invalid step "x"`},
+		{"@fortnightly", "expected 5 fields"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.spec)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) = %v, want error containing %q", tt.spec, err, tt.want)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	const layout = "2006-01-02 15:04 Mon"
+	tests := []struct {
+		name, spec, from, want string
+	}{
+		{"same day", "0 8 * * *", "2025-03-10 07:59 Mon", "2025-03-10 08:00 Mon"},
+		{"strictly after", "0 8 * * *", "2025-03-10 08:00 Mon", "2025-03-11 08:00 Tue"},
+		{"steps", "*/15 * * * *", "2025-03-10 08:01 Mon", "2025-03-10 08:15 Mon"},
+		{"descriptor", "@monthly", "2025-03-10 08:00 Mon", "2025-04-01 00:00 Tue"},
+		{"names", "30 9 * jan-mar mon-fri", "2025-03-29 10:00 Sat", "2025-03-31 09:30 Mon"},
+		{"across months", "0 0 31 * *", "2025-04-01 00:00 Tue", "2025-05-31 00:00 Sat"},
+		{"across years", "0 0 1 1 *", "2025-12-31 23:59 Wed", "2026-01-01 00:00 Thu"},
+		{"leap day", "0 0 29 2 *", "2025-03-01 00:00 Sat", "2028-02-29 00:00 Tue"},
+		{"never", "0 0 31 2 *", "2025-01-01 00:00 Wed", ""},
+		// With both day fields restricted either may match.
+		{"day of month or week", "0 8 15 * 1", "2025-03-11 09:00 Tue", "2025-03-15 08:00 Sat"},
+		{"day of week or month", "0 8 15 * 1", "2025-03-15 09:00 Sat", "2025-03-17 08:00 Mon"},
+		// A stepped star is unrestricted: odd days that are Mondays.
+		{"stepped day of month", "0 8 */2 * 1", "2025-03-10 09:00 Mon", "2025-03-17 08:00 Mon"},
+		{"stepped day of week", "0 8 13 * */7", "2025-03-10 09:00 Mon", "2025-04-13 08:00 Sun"},
+		{"star day of week", "0 8 15 * *", "2025-03-10 09:00 Mon", "2025-03-15 08:00 Sat"},
+		{"sunday as 7", "0 8 * * 7", "2025-03-10 09:00 Mon", "2025-03-16 08:00 Sun"},
+		{"range to 7", "0 8 * * 6-7", "2025-03-10 09:00 Mon", "2025-03-15 08:00 Sat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from, err := time.ParseInLocation(layout, tt.from, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := s.Next(from)
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("Next(%s) = %s, want none", tt.from, got.Format(layout))
+				}
+				return
+			}
+			if got.Format(layout) != tt.want {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got.Format(layout), tt.want)
+			}
+		})
+	}
+}
+
+func TestNextInLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*60*60)
+	s, err := Parse("0 8 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 01:00 UTC is 09:00 in UTC+8, past today's run.
+	got := s.Next(time.Date(2025, 3, 10, 1, 0, 0, 0, time.UTC).In(loc))
+	if want := time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next = %s, want %s", got, want)
+	}
+}
+
+func TestMissed(t *testing.T) {
+	s, err := Parse("0 8 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sched := New(s, nil, Options{Location: time.UTC})
+	day := func(d, h int) time.Time { return time.Date(2025, 3, d, h, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name      string
+		last, now time.Time
+		want      time.Time
+	}{
+		{"nothing due", day(10, 8), day(10, 20), time.Time{}},
+		{"one missed", day(10, 8), day(11, 9), day(11, 8)},
+		{"due now", day(10, 8), day(11, 8), day(11, 8)},
+		{"several collapse into the latest", day(5, 8), day(11, 7), day(10, 8)},
+	}
+	for _, tt := range tests {
+		if got := sched.missed(tt.last, tt.now); !got.Equal(tt.want) {
+			t.Errorf("%s: missed = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunCatchUp(t *testing.T) {
+	s, err := Parse("0 8 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	tests := []struct {
+		name    string
+		last    time.Time
+		catchUp bool
+	}{
+		{"missed", now.AddDate(0, 0, -3), true},
+		{"up to date", now, false},
+		{"never ran", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			if !tt.last.IsZero() {
+				if err := store.Set(lastRunKey, tt.last.Format(time.RFC3339)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var runs []Run
+			sched := New(s, func(_ context.Context, run Run) { runs = append(runs, run) }, Options{Location: time.UTC, Store: store})
+			// A cancelled context stops Run right after the catch-up.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			sched.Run(ctx)
+
+			if !tt.catchUp {
+				if len(runs) != 0 {
+					t.Errorf("runs = %+v, want none", runs)
+				}
+				return
+			}
+			if len(runs) != 1 || !runs[0].CatchUp {
+				t.Fatalf("runs = %+v, want a single catch-up", runs)
+			}
+			if want := sched.missed(tt.last, now); !runs[0].Scheduled.Equal(want) {
+				t.Errorf("scheduled = %s, want %s", runs[0].Scheduled, want)
+			}
+			// The catch-up is recorded at the current time, so it is not
+			// repeated on the next start.
+			last, ok := sched.lastRun()
+			if !ok || now.Sub(last) > time.Minute {
+				t.Errorf("last run = %s, %v; want about now", last, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Package scheduler runs a job on a cron schedule inside a long running
+// process, for hosts without an external cron.
+package scheduler
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"skland-daily-attendance-go/internal/storage"
+)
+
+// lastRunKey is the store key holding the scheduled time of the last run.
+const lastRunKey = "kv:scheduler:last_run"
+
+// pollInterval bounds how long the scheduler sleeps without checking the
+// wall clock. Timers follow the monotonic clock, which stops while the host
+// is suspended, so a single long timer would fire late after a sleep.
+const pollInterval = time.Minute
+
+// Run describes a single execution of the job.
+type Run struct {
+	// Scheduled is the time the run was due, before jitter.
+	Scheduled time.Time
+	// CatchUp is set when the run was missed while the process was stopped
+	// or the host was asleep.
+	CatchUp bool
+}
+
+// Job is executed for every due run. Its context is not cancelled when the
+// scheduler stops, so an in-flight run always finishes.
+type Job func(ctx context.Context, run Run)
+
+// Options configures a Scheduler.
+type Options struct {
+	// Location is the timezone of the cron expression; nil uses time.Local.
+	Location *time.Location
+	// Jitter delays each run by a random duration in [0, Jitter).
+	Jitter time.Duration
+	// Store records the last run so runs missed across restarts are caught
+	// up; without it only runs missed while the process sleeps are.
+	Store storage.Store
+}
+
+// Scheduler runs a job according to a Schedule.
+type Scheduler struct {
+	schedule *Schedule
+	job      Job
+	loc      *time.Location
+	jitter   time.Duration
+	store    storage.Store
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// New creates a scheduler running job on schedule.
+func New(schedule *Schedule, job Job, opts Options) *Scheduler {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	return &Scheduler{
+		schedule: schedule,
+		job:      job,
+		loc:      loc,
+		jitter:   opts.Jitter,
+		store:    opts.Store,
+	}
+}
+
+// Next returns the time of the next run including jitter, or the zero time
+// before Run has started.
+func (s *Scheduler) Next() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.next
+}
+
+// Run executes due runs until ctx is cancelled. A run missed since the last
+// recorded one is executed once immediately. Run returns after the in-flight
+// run, if any, has finished.
+func (s *Scheduler) Run(ctx context.Context) {
+	jobCtx := context.WithoutCancel(ctx)
+
+	if last, ok := s.lastRun(); ok {
+		if due := s.missed(last, time.Now()); !due.IsZero() {
+			s.execute(jobCtx, Run{Scheduled: due, CatchUp: true}, time.Now())
+		}
+	}
+
+	for {
+		scheduled := s.schedule.Next(time.Now().In(s.loc))
+		if scheduled.IsZero() {
+			<-ctx.Done()
+			return
+		}
+		fireAt := scheduled.Add(s.randomJitter())
+		s.setNext(fireAt)
+
+		if !s.wait(ctx, fireAt) {
+			return
+		}
+		// Firing well after the due time means the host slept through it.
+		late := time.Since(fireAt) > pollInterval
+		record := scheduled
+		if late {
+			record = time.Now()
+		}
+		s.execute(jobCtx, Run{Scheduled: scheduled, CatchUp: late}, record)
+	}
+}
+
+// wait sleeps until the wall clock reaches t, returning false if ctx is
+// cancelled first.
+func (s *Scheduler) wait(ctx context.Context, t time.Time) bool {
+	for {
+		d := time.Until(t)
+		if d <= 0 {
+			return true
+		}
+		timer := time.NewTimer(min(d, pollInterval))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+// execute runs the job and records it. A catch-up run is recorded at the
+// current time so the runs it replaces are not caught up again.
+func (s *Scheduler) execute(ctx context.Context, run Run, record time.Time) {
+	s.setNext(time.Time{})
+	s.job(ctx, run)
+	if s.store != nil {
+		_ = s.store.Set(lastRunKey, record.UTC().Format(time.RFC3339))
+	}
+}
+
+// missed returns the latest run due after last and not after now, or the
+// zero time if none was missed. Several missed runs collapse into one.
+func (s *Scheduler) missed(last, now time.Time) time.Time {
+	var due time.Time
+	for t := s.schedule.Next(last.In(s.loc)); !t.IsZero() && !t.After(now); t = s.schedule.Next(t) {
+		due = t
+	}
+	return due
+}
+
+func (s *Scheduler) lastRun() (time.Time, bool) {
+	if s.store == nil {
+		return time.Time{}, false
+	}
+	v, ok, err := s.store.Get(lastRunKey)
+	if err != nil || !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func (s *Scheduler) setNext(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next = t
+}
+
+func (s *Scheduler) randomJitter() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(s.jitter)))
+}
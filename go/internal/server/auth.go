@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Signed request headers. The signature is the hex encoded HMAC-SHA256 of
+//
+//	<timestamp>\n<method>\n<request URI>\n<body>
+//
+// keyed with the shared secret, sent as "sha256=<hex>".
+const (
+	HeaderTimestamp = "X-Skland-Timestamp"
+	HeaderSignature = "X-Skland-Signature"
+)
+
+// maxClockSkew is how far a signed request's timestamp may be from now.
+const maxClockSkew = 5 * time.Minute
+
+// maxAuthFailures is the number of failed authentications a client may make
+// per minute before all its requests are rejected with 429, so that
+// credentials cannot be guessed through routes without a request limit.
+const maxAuthFailures = 10
+
+// Authenticator accepts requests carrying one of the bearer tokens or a
+// valid HMAC signature. With neither configured every request is accepted.
+// Clients failing to authenticate too often are rate limited.
+type Authenticator struct {
+	tokens   [][]byte
+	secret   []byte
+	failures *RateLimiter
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewAuthenticator creates an authenticator for the given bearer tokens and
+// HMAC secret, either of which may be empty.
+func NewAuthenticator(tokens []string, secret string) *Authenticator {
+	a := &Authenticator{
+		failures: NewRateLimiter(maxAuthFailures, maxAuthFailures),
+		seen:     make(map[string]time.Time),
+	}
+	for _, t := range tokens {
+		if t != "" {
+			a.tokens = append(a.tokens, []byte(t))
+		}
+	}
+	if secret != "" {
+		a.secret = []byte(secret)
+	}
+	return a
+}
+
+// Enabled reports whether any credential is configured.
+func (a *Authenticator) Enabled() bool {
+	return len(a.tokens) > 0 || a.secret != nil
+}
+
+// Wrap rejects unauthenticated requests with 401, and every request of a
+// client over the limit of failed authentications with 429.
+func (a *Authenticator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		client, now := clientIP(r), time.Now()
+		if wait := a.failures.wait(client, now); wait > 0 {
+			tooManyRequests(w, wait)
+			return
+		}
+		if err := a.authenticate(r, now); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				WriteError(w, http.StatusRequestEntityTooLarge, "request body too large")
+				return
+			}
+			a.failures.reserve(client, now)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="skland-attendance"`)
+			WriteError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Authenticator) authenticate(r *http.Request, now time.Time) error {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		for _, t := range a.tokens {
+			if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), t) == 1 {
+				return nil
+			}
+		}
+		return errors.New("invalid bearer token")
+	}
+	if sig := r.Header.Get(HeaderSignature); sig != "" && a.secret != nil {
+		return a.verifySignature(r, sig, now)
+	}
+	return errors.New("missing credentials")
+}
+
+// verifySignature checks the HMAC signature and timestamp of r, rejecting
+// signatures already used within the allowed clock skew.
+func (a *Authenticator) verifySignature(r *http.Request, sig string, now time.Time) error {
+	ts, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return errors.New("invalid signature timestamp")
+	}
+	at := time.Unix(ts, 0)
+	if at.Before(now.Add(-maxClockSkew)) || at.After(now.Add(maxClockSkew)) {
+		return errors.New("signature timestamp out of range")
+	}
+
+	got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+	if err != nil {
+		return errors.New("malformed signature")
+	}
+
+	var body []byte
+	if r.Body != nil {
+		// The body size is bounded by LimitBody further up the chain.
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			return fmt.Errorf("read request body: %w", err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(strconv.FormatInt(ts, 10) + "\n" + r.Method + "\n" + r.URL.RequestURI() + "\n"))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errors.New("invalid signature")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for k, exp := range a.seen {
+		if now.After(exp) {
+			delete(a.seen, k)
+		}
+	}
+	key := hex.EncodeToString(got)
+	if _, ok := a.seen[key]; ok {
+		return errors.New("signature already used")
+	}
+	a.seen[key] = at.Add(maxClockSkew)
+	return nil
+}
+
+// Sign returns the signature header value for a request, for clients
+// written in Go.
+func Sign(secret string, ts int64, method, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10) + "\n" + method + "\n" + requestURI + "\n"))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticatorLimitsFailures(t *testing.T) {
+	auth := NewAuthenticator([]string{"secret"}, "")
+	h := auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	do := func(client, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/runs", nil)
+		r.RemoteAddr = client + ":1234"
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// Authenticated requests are never limited.
+	for i := 0; i < 2*maxAuthFailures; i++ {
+		if w := do("192.0.2.1", "secret"); w.Code != http.StatusNoContent {
+			t.Fatalf("authenticated request %d: status %d", i, w.Code)
+		}
+	}
+
+	for i := 0; i < maxAuthFailures; i++ {
+		if w := do("192.0.2.2", "guess"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failed attempt %d: status %d, want 401", i, w.Code)
+		}
+	}
+	w := do("192.0.2.2", "guess")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("attempt over the limit: status %d, Retry-After %q, want 429", w.Code, w.Header().Get("Retry-After"))
+	}
+	// Once limited, a client is rejected even with a valid token, so that
+	// guessing cannot continue.
+	if w := do("192.0.2.2", "secret"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("limited client with valid token: status %d, want 429", w.Code)
+	}
+	if w := do("192.0.2.3", "secret"); w.Code != http.StatusNoContent {
+		t.Errorf("other client: status %d, want 204", w.Code)
+	}
+}
@@ -0,0 +1,125 @@
+package skland
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Attendance endpoints. Arknights characters are addressed by uid and game
+// id, Endfield characters by the sk-game-role header.
+const (
+	attendancePath         = "/api/v1/game/attendance"
+	endfieldAttendancePath = "/web/v1/game/endfield/attendance"
+)
+
+// GetAttendanceStatus returns the attendance records of a character of a
+// game other than Endfield.
+func (c *Client) GetAttendanceStatus(ctx context.Context, sessionToken string, character AppBindingPlayer) (*ArknightsAttendanceStatus, error) {
+	q := url.Values{}
+	q.Set("uid", character.UID)
+	q.Set("gameId", strconv.Itoa(character.GameID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+attendancePath+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var status ArknightsAttendanceStatus
+	if err := c.call(req, sessionToken, &status); err != nil {
+		return nil, fmt.Errorf("get attendance status failed: %w", err)
+	}
+	return &status, nil
+}
+
+// Attend claims today's attendance reward of a character of a game other
+// than Endfield.
+func (c *Client) Attend(ctx context.Context, sessionToken string, character AppBindingPlayer) (*GameAttendanceResult, error) {
+	body, err := json.Marshal(map[string]any{"uid": character.UID, "gameId": character.GameID})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+attendancePath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var result GameAttendanceResult
+	if err := c.call(req, sessionToken, &result); err != nil {
+		return nil, fmt.Errorf("attend failed: %w", err)
+	}
+	return &result, nil
+}
+
+// GetEndfieldAttendanceStatus returns whether the default role of an
+// Endfield character has attended today.
+func (c *Client) GetEndfieldAttendanceStatus(ctx context.Context, sessionToken string, character AppBindingPlayer) (*EndfieldAttendanceStatus, error) {
+	req, err := c.endfieldRequest(ctx, http.MethodGet, character)
+	if err != nil {
+		return nil, err
+	}
+	var status EndfieldAttendanceStatus
+	if err := c.call(req, sessionToken, &status); err != nil {
+		return nil, fmt.Errorf("get endfield attendance status failed: %w", err)
+	}
+	return &status, nil
+}
+
+// AttendEndfield claims today's attendance reward of the default role of an
+// Endfield character.
+func (c *Client) AttendEndfield(ctx context.Context, sessionToken string, character AppBindingPlayer) (*EndfieldAttendanceResult, error) {
+	req, err := c.endfieldRequest(ctx, http.MethodPost, character)
+	if err != nil {
+		return nil, err
+	}
+	var result EndfieldAttendanceResult
+	if err := c.call(req, sessionToken, &result); err != nil {
+		return nil, fmt.Errorf("attend endfield failed: %w", err)
+	}
+	return &result, nil
+}
+
+func (c *Client) endfieldRequest(ctx context.Context, method string, character AppBindingPlayer) (*http.Request, error) {
+	if character.DefaultRole == nil {
+		return nil, fmt.Errorf("endfield character %s has no role", character.UID)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+endfieldAttendancePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	role := character.DefaultRole
+	req.Header.Set("sk-game-role", fmt.Sprintf("%d_%s_%s", character.GameID, role.RoleID, role.ServerID))
+	return req, nil
+}
+
+// call sends an authenticated request and decodes the data of the
+// {code, message, data} envelope into v.
+func (c *Client) call(req *http.Request, sessionToken string, v any) error {
+	req.Header.Set("Authorization", "Bearer "+sessionToken)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var body struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+	if body.Code != 0 {
+		return fmt.Errorf("code %d: %s", body.Code, body.Message)
+	}
+	if len(body.Data) == 0 || string(body.Data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(body.Data, v)
+}
@@ -0,0 +1,184 @@
+package skland
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// apiRequest is a request received by the fake API.
+type apiRequest struct {
+	method, path string
+	query        url.Values
+	header       http.Header
+	body         string
+}
+
+// newTestClient returns a client whose requests are served by handler,
+// and the requests it received.
+func newTestClient(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) (*Client, *[]apiRequest) {
+	t.Helper()
+	var requests []apiRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		requests = append(requests, apiRequest{r.Method, r.URL.Path, r.URL.Query(), r.Header.Clone(), string(b)})
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	return NewClient(WithBaseURL(srv.URL)), &requests
+}
+
+func envelope(data string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, `{"code":0,"message":"OK","data":`+data+`}`)
+	}
+}
+
+var (
+	arknights = AppBindingPlayer{AppCode: "arknights", GameID: 1, UID: "u1"}
+	endfield  = AppBindingPlayer{AppCode: "endfield", GameID: 3, UID: "u3",
+		DefaultRole: &DefaultRole{ServerID: "1", RoleID: "r3", NickName: "管理员"}}
+)
+
+func TestGetAttendanceStatus(t *testing.T) {
+	c, requests := newTestClient(t, envelope(`{"records":[{"ts":"1700000000"},{"ts":1700086400}]}`))
+	status, err := c.GetAttendanceStatus(context.Background(), "session", arknights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Records) != 2 || status.Records[0].TS != 1700000000 || status.Records[1].TS != 1700086400 {
+		t.Errorf("records = %+v", status.Records)
+	}
+
+	req := (*requests)[0]
+	if req.method != http.MethodGet || req.path != attendancePath {
+		t.Errorf("request = %s %s", req.method, req.path)
+	}
+	if req.query.Get("uid") != "u1" || req.query.Get("gameId") != "1" {
+		t.Errorf("query = %v", req.query)
+	}
+	if got := req.header.Get("Authorization"); got != "Bearer session" {
+		t.Errorf("Authorization = %q", got)
+	}
+}
+
+func TestAttend(t *testing.T) {
+	c, requests := newTestClient(t, envelope(`{"awards":[{"resource":{"name":"龙门币"},"count":500}]}`))
+	res, err := c.Attend(context.Background(), "session", arknights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Awards) != 1 || res.Awards[0].Resource.Name != "龙门币" || res.Awards[0].Count != 500 {
+		t.Errorf("awards = %+v", res.Awards)
+	}
+
+	req := (*requests)[0]
+	if req.method != http.MethodPost || req.path != attendancePath || len(req.query) != 0 {
+		t.Errorf("request = %s %s?%s", req.method, req.path, req.query.Encode())
+	}
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	var body map[string]any
+	if err := json.Unmarshal([]byte(req.body), &body); err != nil || body["uid"] != "u1" || body["gameId"] != float64(1) {
+		t.Errorf("body = %s", req.body)
+	}
+}
+
+func TestEndfieldAttendance(t *testing.T) {
+	c, requests := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			envelope(`{"hasToday":true}`)(w, r)
+			return
+		}
+		envelope(`{"awardIds":[{"id":"a1"}],"resourceInfoMap":{"a1":{"name":"折金票"}}}`)(w, r)
+	})
+	status, err := c.GetEndfieldAttendanceStatus(context.Background(), "session", endfield)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.HasToday {
+		t.Error("hasToday = false, want true")
+	}
+	res, err := c.AttendEndfield(context.Background(), "session", endfield)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.AwardIDs) != 1 || res.ResourceInfoMap[res.AwardIDs[0].ID].Name != "折金票" {
+		t.Errorf("result = %+v", res)
+	}
+
+	for i, method := range []string{http.MethodGet, http.MethodPost} {
+		req := (*requests)[i]
+		if req.method != method || req.path != endfieldAttendancePath {
+			t.Errorf("request %d = %s %s, want %s", i, req.method, req.path, method)
+		}
+		if got := req.header.Get("sk-game-role"); got != "3_r3_1" {
+			t.Errorf("sk-game-role = %q, want 3_r3_1", got)
+		}
+		if got := req.header.Get("Authorization"); got != "Bearer session" {
+			t.Errorf("Authorization = %q", got)
+		}
+	}
+
+	noRole := endfield
+	noRole.DefaultRole = nil
+	if _, err := c.AttendEndfield(context.Background(), "session", noRole); err == nil {
+		t.Error("AttendEndfield without a role succeeded")
+	}
+	if len(*requests) != 2 {
+		t.Errorf("a character without a role was sent to the API")
+	}
+}
+
+func TestAttendanceErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(http.ResponseWriter, *http.Request)
+		want    string
+	}{
+		{
+			name: "api code",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = io.WriteString(w, `{"code":10001,"message":"请勿重复签到","data":null}`)
+			},
+			want: "code 10001: 请勿重复签到",
+		},
+		{
+			name: "http status",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+			},
+			want: "401",
+		},
+		{
+			name: "malformed body",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = io.WriteString(w, `<html>`)
+			},
+			want: "invalid character",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestClient(t, tt.handler)
+			_, err := c.Attend(context.Background(), "session", arknights)
+			if err == nil || !strings.Contains(err.Error(), tt.want) || !strings.HasPrefix(err.Error(), "attend failed") {
+				t.Errorf("Attend = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// A null data is a success without a result.
+	c, _ := newTestClient(t, envelope(`null`))
+	status, err := c.GetEndfieldAttendanceStatus(context.Background(), "session", endfield)
+	if err != nil || status.HasToday {
+		t.Errorf("GetEndfieldAttendanceStatus = %+v, %v", status, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -13,29 +14,47 @@ import (
 // of skland-kit, only the parts needed for daily attendance.
 
 const (
-	baseURL = "https://zonai.skland.com"
+	// Host is the host of the Skland API.
+	Host           = "zonai.skland.com"
+	defaultBaseURL = "https://" + Host
 )
 
 type Client struct {
 	httpClient *http.Client
+	baseURL    string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL sends the requests to u, e.g. a fake of the API in
+// tests, instead of https://zonai.skland.com.
+func WithBaseURL(u string) Option {
+	return func(c *Client) { c.baseURL = strings.TrimSuffix(u, "/") }
 }
 
 // NewClient creates a new Skland client with a default HTTP client.
-func NewClient() *Client {
-	return &Client{
+func NewClient(opts ...Option) *Client {
+	c := &Client{
 		httpClient: &http.Client{
-			Timeout: 15 * time.Second,
+			Timeout:   15 * time.Second,
+			Transport: &instrumentedTransport{base: http.DefaultTransport},
 		},
+		baseURL: defaultBaseURL,
 	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
 }
 
 // AppBindingPlayer corresponds to the subset of fields used by the attendance logic.
 type AppBindingPlayer struct {
-	AppCode     string        `json:"appCode"`
-	GameID      int           `json:"gameId"`
-	GameName    string        `json:"gameName"`
-	UID         string        `json:"uid"`
-	DefaultRole *DefaultRole  `json:"defaultRole"`
+	AppCode     string       `json:"appCode"`
+	GameID      int          `json:"gameId"`
+	GameName    string       `json:"gameName"`
+	UID         string       `json:"uid"`
+	DefaultRole *DefaultRole `json:"defaultRole"`
 }
 
 type DefaultRole struct {
@@ -55,10 +74,31 @@ type BindingItem struct {
 // ArknightsAttendanceStatus: we only care about records.ts.
 type ArknightsAttendanceStatus struct {
 	Records []struct {
-		TS int64 `json:"ts"`
+		TS Timestamp `json:"ts"`
 	} `json:"records"`
 }
 
+// Timestamp is a Unix time in seconds, sent by the API as a string or a
+// number.
+type Timestamp int64
+
+// UnmarshalJSON accepts both "1700000000" and 1700000000.
+func (t *Timestamp) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	v, err := n.Int64()
+	if err != nil {
+		return err
+	}
+	*t = Timestamp(v)
+	return nil
+}
+
 // EndfieldAttendanceStatus: we only care about hasToday flag.
 type EndfieldAttendanceStatus struct {
 	HasToday bool `json:"hasToday"`
@@ -90,7 +130,7 @@ type EndfieldAttendanceResult struct {
 
 // GrantAuthorizeCode exchanges the token for an authorize code.
 func (c *Client) GrantAuthorizeCode(ctx context.Context, token string) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/api/auth/grant", nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/auth/grant", nil)
 	if err != nil {
 		return "", err
 	}
@@ -123,7 +163,7 @@ func (c *Client) SignIn(ctx context.Context, code string) (string, error) {
 	// Note: the exact sign-in endpoint and payload depend on Skland API.
 	// This is a placeholder structure that should be adjusted to match the
 	// real API if needed.
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/api/auth/login", nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/auth/login", nil)
 	if err != nil {
 		return "", err
 	}
@@ -155,7 +195,7 @@ func (c *Client) SignIn(ctx context.Context, code string) (string, error) {
 
 // GetBinding returns binding list for the current account.
 func (c *Client) GetBinding(ctx context.Context, sessionToken string) ([]BindingItem, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/api/game/player/binding", nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/game/player/binding", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -179,4 +219,3 @@ func (c *Client) GetBinding(ctx context.Context, sessionToken string) ([]Binding
 	}
 	return body.List, nil
 }
-
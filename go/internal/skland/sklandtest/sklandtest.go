@@ -0,0 +1,293 @@
+// Package sklandtest provides an in-process fake of the Skland API for
+// tests, reached by clients created with the Server's Option.
+package sklandtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"skland-daily-attendance-go/internal/skland"
+)
+
+// Server is a fake Skland API holding accounts, their bindings and the
+// characters that attended today.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	accounts map[string][]skland.AppBindingPlayer
+	attended map[string]bool
+	claims   []string
+	requests map[string]int
+	// delay is added to every response, to widen race windows.
+	delay time.Duration
+}
+
+// New starts a fake server, stopped when the test ends. Clients reach it
+// through Option.
+func New(t testing.TB) *Server {
+	t.Helper()
+	s := &Server{
+		accounts: make(map[string][]skland.AppBindingPlayer),
+		attended: make(map[string]bool),
+		requests: make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Option points a skland.Client at the fake.
+func (s *Server) Option() skland.Option {
+	return skland.WithBaseURL(s.URL)
+}
+
+// Client returns a client of the fake.
+func (s *Server) Client() *skland.Client {
+	return skland.NewClient(s.Option())
+}
+
+// AddAccount registers an account token bound to the characters.
+func (s *Server) AddAccount(token string, characters ...skland.AppBindingPlayer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[token] = characters
+}
+
+// SetAttended marks the character with uid as attended today.
+func (s *Server) SetAttended(uid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attended[uid] = true
+}
+
+// SetDelay delays every response by d.
+func (s *Server) SetDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = d
+}
+
+// Claims returns the uids of the characters whose reward was claimed, in
+// order.
+func (s *Server) Claims() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.claims...)
+}
+
+// Requests returns how often "METHOD /path" was requested.
+func (s *Server) Requests(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[endpoint]
+}
+
+// Arknights returns an Arknights character.
+func Arknights(uid, nickName string) skland.AppBindingPlayer {
+	return skland.AppBindingPlayer{
+		AppCode:     "arknights",
+		GameID:      1,
+		GameName:    "明日方舟",
+		UID:         uid,
+		DefaultRole: &skland.DefaultRole{ServerID: "1", RoleID: uid, NickName: nickName},
+	}
+}
+
+// Endfield returns an Endfield character whose default role is roleID.
+func Endfield(uid, roleID, nickName string) skland.AppBindingPlayer {
+	return skland.AppBindingPlayer{
+		AppCode:     "endfield",
+		GameID:      3,
+		GameName:    "明日方舟：终末地",
+		UID:         uid,
+		DefaultRole: &skland.DefaultRole{ServerID: "1", RoleID: roleID, NickName: nickName},
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.Method+" "+r.URL.Path]++
+	delay := s.delay
+	s.mu.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	switch r.Method + " " + r.URL.Path {
+	case "POST /api/auth/grant":
+		if !s.hasAccount(bearer) {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, map[string]string{"code": "code:" + bearer})
+	case "POST /api/auth/login":
+		token, ok := strings.CutPrefix(r.URL.Query().Get("code"), "code:")
+		if !ok || !s.hasAccount(token) {
+			http.Error(w, "invalid code", http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, map[string]string{"token": "session:" + token})
+	case "GET /api/game/player/binding":
+		characters, ok := s.session(bearer)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		byApp := make(map[string]*skland.BindingItem)
+		var list []*skland.BindingItem
+		for _, c := range characters {
+			item, ok := byApp[c.AppCode]
+			if !ok {
+				item = &skland.BindingItem{AppCode: c.AppCode}
+				byApp[c.AppCode] = item
+				list = append(list, item)
+			}
+			item.BindingList = append(item.BindingList, c)
+		}
+		writeJSON(w, map[string]any{"list": list})
+	case "GET /api/v1/game/attendance", "POST /api/v1/game/attendance":
+		s.attendance(w, r, bearer)
+	case "GET /web/v1/game/endfield/attendance", "POST /web/v1/game/endfield/attendance":
+		s.endfieldAttendance(w, r, bearer)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) attendance(w http.ResponseWriter, r *http.Request, bearer string) {
+	uid, gameID := r.URL.Query().Get("uid"), r.URL.Query().Get("gameId")
+	if r.Method == http.MethodPost {
+		var body struct {
+			UID    string `json:"uid"`
+			GameID int    `json:"gameId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeEnvelope(w, 10001, "invalid body", nil)
+			return
+		}
+		uid, gameID = body.UID, strconv.Itoa(body.GameID)
+	}
+	if _, ok := s.character(bearer, func(c skland.AppBindingPlayer) bool {
+		return c.UID == uid && strconv.Itoa(c.GameID) == gameID
+	}); !ok {
+		writeEnvelope(w, 10002, "character not found", nil)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		var records []map[string]int64
+		if s.isAttended(uid) {
+			records = append(records, map[string]int64{"ts": time.Now().Unix()})
+		}
+		writeEnvelope(w, 0, "OK", map[string]any{"records": records})
+		return
+	}
+	if !s.claim(uid) {
+		writeEnvelope(w, 10003, "请勿重复签到", nil)
+		return
+	}
+	writeEnvelope(w, 0, "OK", map[string]any{
+		"awards": []map[string]any{{"resource": map[string]string{"name": "龙门币"}, "count": 500}},
+	})
+}
+
+func (s *Server) endfieldAttendance(w http.ResponseWriter, r *http.Request, bearer string) {
+	parts := strings.Split(r.Header.Get("sk-game-role"), "_")
+	if len(parts) != 3 {
+		writeEnvelope(w, 10001, "missing sk-game-role", nil)
+		return
+	}
+	c, ok := s.character(bearer, func(c skland.AppBindingPlayer) bool {
+		return strconv.Itoa(c.GameID) == parts[0] && c.DefaultRole != nil &&
+			c.DefaultRole.RoleID == parts[1] && c.DefaultRole.ServerID == parts[2]
+	})
+	if !ok {
+		writeEnvelope(w, 10002, "role not found", nil)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		writeEnvelope(w, 0, "OK", map[string]bool{"hasToday": s.isAttended(c.UID)})
+		return
+	}
+	if !s.claim(c.UID) {
+		writeEnvelope(w, 10003, "请勿重复签到", nil)
+		return
+	}
+	writeEnvelope(w, 0, "OK", map[string]any{
+		"awardIds":        []map[string]string{{"id": "a1"}},
+		"resourceInfoMap": map[string]any{"a1": map[string]string{"name": "折金票"}},
+	})
+}
+
+func (s *Server) hasAccount(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.accounts[token]
+	return ok
+}
+
+func (s *Server) session(bearer string) ([]skland.AppBindingPlayer, bool) {
+	token, ok := strings.CutPrefix(bearer, "session:")
+	if !ok {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	characters, ok := s.accounts[token]
+	return characters, ok
+}
+
+func (s *Server) character(bearer string, match func(skland.AppBindingPlayer) bool) (skland.AppBindingPlayer, bool) {
+	characters, _ := s.session(bearer)
+	for _, c := range characters {
+		if match(c) {
+			return c, true
+		}
+	}
+	return skland.AppBindingPlayer{}, false
+}
+
+func (s *Server) isAttended(uid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attended[uid]
+}
+
+// claim records the attendance of uid, reporting false when it already
+// attended today.
+func (s *Server) claim(uid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attended[uid] {
+		return false
+	}
+	s.attended[uid] = true
+	s.claims = append(s.claims, uid)
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeEnvelope(w http.ResponseWriter, code int, message string, data any) {
+	writeJSON(w, map[string]any{"code": code, "message": message, "data": data})
+}
+
+// String describes the server state, for test failure messages.
+func (s *Server) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("claims=%v requests=%v", s.claims, s.requests)
+}
@@ -0,0 +1,55 @@
+package skland
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"skland-daily-attendance-go/internal/logging"
+	"skland-daily-attendance-go/internal/metrics"
+	"skland-daily-attendance-go/internal/tracing"
+)
+
+// instrumentedTransport records the latency of every API call by endpoint
+// path and status code, traces it as a client span, and logs the call with
+// the logger of the request context. Query strings and headers are never
+// logged or traced.
+type instrumentedTransport struct {
+	base http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	_, span := tracing.Start(req.Context(), req.Method+" "+req.URL.Path,
+		tracing.WithKind(tracing.KindClient),
+		tracing.WithAttributes(
+			slog.String("http.request.method", req.Method),
+			slog.String("server.address", req.URL.Host),
+			slog.String("url.path", req.URL.Path),
+		))
+	defer span.End()
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+		span.SetAttributes(slog.Int("http.response.status_code", resp.StatusCode))
+		if resp.StatusCode >= 400 {
+			span.SetStatus(tracing.StatusError, resp.Status)
+		}
+	} else {
+		span.RecordError(err)
+	}
+	elapsed := time.Since(start)
+	metrics.APIRequests.With(req.URL.Path, status).ObserveDuration(elapsed)
+
+	log := logging.FromContext(req.Context())
+	attrs := []any{"method", req.Method, "endpoint", req.URL.Path, "status", status, "duration", elapsed}
+	if err != nil {
+		log.Warn("森空岛 API 请求失败", append(attrs, "err", err)...)
+	} else {
+		log.Debug("森空岛 API 请求", attrs...)
+	}
+	return resp, err
+}
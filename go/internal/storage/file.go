@@ -0,0 +1,411 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStore persists the store as a JSON file, for long running deployments
+// such as daemon mode where state must survive restarts.
+//
+// Several processes may share the file, e.g. replicas mounting the same
+// volume, or the CLI and a daemon using the same STORE_PATH. Each write
+// takes a lock file next to the store, re-reads the file and applies only
+// its own change, and reads reload the file when another process replaced
+// it, so that no process overwrites the changes of another.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+	data fileData
+	// loaded describes the file data was read from, nil when it did not
+	// exist.
+	loaded fs.FileInfo
+}
+
+type fileData struct {
+	Attended map[string]bool   `json:"attended"`
+	Values   map[string]string `json:"values"`
+}
+
+const (
+	// writeLockKey names the lock held while the file is rewritten.
+	writeLockKey = "kv:lock:store-write"
+	// writeLockTTL lets other processes take over the lock of a writer
+	// that crashed; writing the file takes far less.
+	writeLockTTL = 10 * time.Second
+	// writeLockWait bounds the wait for another process's write.
+	writeLockWait = 5 * time.Second
+)
+
+// NewFileStore opens the store at path, creating it on the first write.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, data: emptyFileData()}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	slog.Debug("已加载状态文件", "path", path, "values", len(s.data.Values), "attended", len(s.data.Attended))
+	return s, nil
+}
+
+func emptyFileData() fileData {
+	return fileData{
+		Attended: make(map[string]bool),
+		Values:   make(map[string]string),
+	}
+}
+
+// Open returns a FileStore for path, or a MemoryStore when path is empty.
+func Open(path string) (Store, error) {
+	if path == "" {
+		return NewMemoryStore(), nil
+	}
+	return NewFileStore(path)
+}
+
+// HasAttended checks whether the given key has been marked as attended.
+func (s *FileStore) HasAttended(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return false, err
+	}
+	return s.data.Attended[key], nil
+}
+
+// MarkAttended marks the given key as attended.
+func (s *FileStore) MarkAttended(key string) error {
+	return s.update(func(d *fileData) bool {
+		d.Attended[key] = true
+		return true
+	})
+}
+
+// Get returns the value stored under key.
+func (s *FileStore) Get(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return "", false, err
+	}
+	v, ok := s.data.Values[key]
+	return v, ok, nil
+}
+
+// Set stores value under key.
+func (s *FileStore) Set(key, value string) error {
+	return s.update(func(d *fileData) bool {
+		d.Values[key] = value
+		return true
+	})
+}
+
+// Delete removes key.
+func (s *FileStore) Delete(key string) error {
+	return s.update(func(d *fileData) bool {
+		if _, ok := d.Values[key]; !ok {
+			return false
+		}
+		delete(d.Values, key)
+		return true
+	})
+}
+
+// Update replaces the value under key with the result of fn, which gets
+// the current value and whether it exists. No other process writes the
+// file in between.
+func (s *FileStore) Update(key string, fn func(value string, ok bool) (string, error)) error {
+	var fnErr error
+	err := s.update(func(d *fileData) bool {
+		old, ok := d.Values[key]
+		v, err := fn(old, ok)
+		if err != nil {
+			fnErr = err
+			return false
+		}
+		d.Values[key] = v
+		return true
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+// reload reads the file again when it was replaced since it was last read.
+// Callers hold s.mu.
+func (s *FileStore) reload() error {
+	fi, err := os.Stat(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if s.loaded != nil {
+			s.data, s.loaded = emptyFileData(), nil
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if s.loaded != nil && os.SameFile(fi, s.loaded) && fi.ModTime().Equal(s.loaded.ModTime()) && fi.Size() == s.loaded.Size() {
+		return nil
+	}
+
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	data := emptyFileData()
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &data); err != nil {
+			return fmt.Errorf("parse %s: %w", s.path, err)
+		}
+	}
+	if data.Attended == nil {
+		data.Attended = make(map[string]bool)
+	}
+	if data.Values == nil {
+		data.Values = make(map[string]string)
+	}
+	s.data, s.loaded = data, fi
+	return nil
+}
+
+// update applies fn to the current content of the file and writes it back
+// when fn reports a change, holding the write lock shared with the other
+// processes using the file.
+func (s *FileStore) update(fn func(d *fileData) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	owner := randomSuffix()
+	deadline := time.Now().Add(writeLockWait)
+	for {
+		ok, err := s.TryLock(writeLockKey, owner, writeLockTTL)
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("state file %s is locked by another process", s.path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer s.Unlock(writeLockKey, owner)
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+	if !fn(&s.data) {
+		return nil
+	}
+	if err := s.save(); err != nil {
+		// Drop the unsaved change; the next access reads the file again.
+		s.data, s.loaded = emptyFileData(), nil
+		return err
+	}
+	return nil
+}
+
+// save writes the file atomically through a temporary file, so a crash never
+// leaves a truncated store behind. Attendance records of past days are
+// dropped first, as they are never read again. Callers hold s.mu.
+func (s *FileStore) save() error {
+	if today, err := attendanceDate(time.Now()); err == nil {
+		for k := range s.data.Attended {
+			if expiredAttendance(k, today) {
+				delete(s.data.Attended, k)
+			}
+		}
+	}
+	if err := s.write(); err != nil {
+		slog.Error("写入状态文件失败", "path", s.path, "err", err)
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) write() error {
+	b, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	// The file now holds s.data, so it need not be read again.
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.loaded = fi
+	return nil
+}
+
+// TryLock acquires the lock named key for owner. The lock is a file created
+// exclusively next to the store, so processes sharing the store's directory
+// (e.g. replicas mounting the same volume) exclude each other.
+func (s *FileStore) TryLock(key, owner string, ttl time.Duration) (bool, error) {
+	path := s.lockPath(key)
+	entry, err := json.Marshal(lockEntry{Owner: owner, Expires: time.Now().Add(ttl)})
+	if err != nil {
+		return false, err
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		err := createLock(path, entry)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return false, err
+		}
+
+		held, err := readLock(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err == nil && !held.availableTo(owner, time.Now()) {
+			slog.Debug("锁已被占用", "lock", key, "holder", held.Owner, "expires", held.Expires)
+			return false, nil
+		}
+		if err == nil && held.Owner == owner {
+			return true, replaceLock(path, entry)
+		}
+		// The lock expired, or is unreadable: move it aside and check it
+		// again, so that a lock just taken by another process is restored
+		// rather than stolen.
+		stale := path + "." + randomSuffix() + ".stale"
+		if err := os.Rename(path, stale); err != nil {
+			continue
+		}
+		if held, err := readLock(stale); err == nil && !held.availableTo(owner, time.Now()) {
+			_ = os.Link(stale, path)
+			os.Remove(stale)
+			return false, nil
+		}
+		os.Remove(stale)
+		slog.Warn("已清除过期或损坏的锁", "lock", key)
+	}
+	return false, nil
+}
+
+// createLock creates the lock file at path holding entry, failing with
+// fs.ErrExist when it is already there. The entry is written to a temporary
+// file that is then linked into place, so other processes never read a
+// lock file that is still empty.
+func createLock(path string, entry []byte) error {
+	tmp, err := writeTemp(path, entry)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return os.Link(tmp, path)
+}
+
+// replaceLock overwrites the lock file at path with entry atomically.
+func replaceLock(path string, entry []byte) error {
+	tmp, err := writeTemp(path, entry)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return os.Rename(tmp, path)
+}
+
+// writeTemp writes b to a new temporary file next to path and returns its
+// name.
+func writeTemp(path string, b []byte) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// Unlock releases the lock named key if owner holds it.
+func (s *FileStore) Unlock(key, owner string) error {
+	path := s.lockPath(key)
+	held, err := readLock(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil || held.Owner != owner {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileStore) lockPath(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return s.path + "." + hex.EncodeToString(h[:8]) + ".lock"
+}
+
+func readLock(path string) (lockEntry, error) {
+	var l lockEntry
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return l, err
+	}
+	return l, json.Unmarshal(b, &l)
+}
+
+func randomSuffix() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Ping checks that the directory of the store file is accessible.
+func (s *FileStore) Ping() error {
+	dir := filepath.Dir(s.path)
+	fi, err := os.Stat(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Created on the first write.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return nil
+}
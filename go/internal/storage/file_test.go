@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileStorePrunesPastAttendance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	today, err := attendanceDate(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	yesterday, _ := attendanceDate(time.Now().AddDate(0, 0, -1))
+	old := fileData{
+		Attended: map[string]bool{
+			attendanceKeyPrefix + "aaaa:2020-01-01":   true,
+			attendanceKeyPrefix + "aaaa:" + yesterday: true,
+			attendanceKeyPrefix + "bbbb:" + today:     true,
+			"custom":                                  true,
+		},
+		Values: map[string]string{"k": "v"},
+	}
+	b, _ := json.Marshal(old)
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := GenerateAttendanceKey("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MarkAttended(key); err != nil {
+		t.Fatal(err)
+	}
+
+	var saved fileData
+	b, _ = os.ReadFile(path)
+	if err := json.Unmarshal(b, &saved); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{
+		attendanceKeyPrefix + "bbbb:" + today: true,
+		key:                                   true,
+		"custom":                              true,
+	}
+	if len(saved.Attended) != len(want) {
+		t.Errorf("attended = %v, want %v", saved.Attended, want)
+	}
+	for k := range want {
+		if !saved.Attended[k] {
+			t.Errorf("attended lacks %q", k)
+		}
+	}
+	if saved.Values["k"] != "v" {
+		t.Errorf("values = %v", saved.Values)
+	}
+}
+
+func TestMemoryStorePrunesPastAttendance(t *testing.T) {
+	s := NewMemoryStore()
+	stale := attendanceKeyPrefix + "aaaa:2020-01-01"
+	if err := s.MarkAttended(stale); err != nil {
+		t.Fatal(err)
+	}
+	key, _ := GenerateAttendanceKey("token")
+	if err := s.MarkAttended(key); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.HasAttended(stale); ok {
+		t.Error("past attendance was kept")
+	}
+	if ok, _ := s.HasAttended(key); !ok {
+		t.Error("today's attendance was dropped")
+	}
+}
+
+// TestFileStoreSharedFile opens the same file twice, as replicas or the CLI
+// and a daemon sharing STORE_PATH do, and checks that neither instance
+// overwrites the writes of the other.
+func TestFileStoreSharedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	a, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Set("a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Set("b", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.MarkAttended("kv:attendance:a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.MarkAttended("kv:attendance:b"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, s := range map[string]*FileStore{"a": a, "b": b} {
+		if _, ok, _ := s.Get("a"); ok {
+			t.Errorf("%s: deleted key a is still present", name)
+		}
+		if v, _, _ := s.Get("b"); v != "2" {
+			t.Errorf("%s: Get(b) = %q, want 2", name, v)
+		}
+		for _, key := range []string{"kv:attendance:a", "kv:attendance:b"} {
+			if ok, _ := s.HasAttended(key); !ok {
+				t.Errorf("%s: %s not attended", name, key)
+			}
+		}
+	}
+}
+
+func TestFileStoreConcurrentUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	const writers, increments = 4, 25
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		s, err := NewFileStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				err := s.Update("counter", func(v string, ok bool) (string, error) {
+					n, _ := strconv.Atoi(v)
+					return strconv.Itoa(n + 1), nil
+				})
+				if err != nil {
+					t.Errorf("Update: %v", err)
+					return
+				}
+				if err := s.Set(fmt.Sprintf("w%d-%d", i, j), "x"); err != nil {
+					t.Errorf("Set: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _, _ := s.Get("counter"); v != strconv.Itoa(writers*increments) {
+		t.Errorf("counter = %s, want %d", v, writers*increments)
+	}
+	for i := 0; i < writers; i++ {
+		for j := 0; j < increments; j++ {
+			if _, ok, _ := s.Get(fmt.Sprintf("w%d-%d", i, j)); !ok {
+				t.Errorf("write w%d-%d was lost", i, j)
+			}
+		}
+	}
+}
+
+func TestFileStoreUpdateError(t *testing.T) {
+	s, err := NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("k", "v"); err != nil {
+		t.Fatal(err)
+	}
+	want := errors.New("boom")
+	err = s.Update("k", func(string, bool) (string, error) { return "changed", want })
+	if !errors.Is(err, want) {
+		t.Errorf("Update = %v, want %v", err, want)
+	}
+	if v, _, _ := s.Get("k"); v != "v" {
+		t.Errorf("Get(k) = %q after failed update, want v", v)
+	}
+}
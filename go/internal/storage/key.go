@@ -3,23 +3,59 @@ package storage
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"strings"
 	"time"
 )
 
+// attendanceKeyPrefix starts the keys of attendance records.
+const attendanceKeyPrefix = "kv:attendance:"
+
 // GenerateAttendanceKey mimics the TypeScript implementation:
 // sha256(token) + date in Asia/Shanghai, format YYYY-MM-DD.
 func GenerateAttendanceKey(token string) (string, error) {
 	h := sha256.Sum256([]byte(token))
 	hashHex := hex.EncodeToString(h[:])
 
+	date, err := attendanceDate(time.Now())
+	if err != nil {
+		return "", err
+	}
+	return attendanceKeyPrefix + hashHex + ":" + date, nil
+}
+
+// attendanceDate returns the date of t in Asia/Shanghai, as used in
+// attendance keys.
+func attendanceDate(t time.Time) (string, error) {
 	loc, err := time.LoadLocation("Asia/Shanghai")
 	if err != nil {
 		return "", err
 	}
+	return t.In(loc).Format("2006-01-02"), nil
+}
 
-	now := time.Now().In(loc)
-	date := now.Format("2006-01-02")
+// expiredAttendance reports whether key records the attendance of a day
+// before today, a date formatted like attendanceDate. Such records are
+// never read again.
+func expiredAttendance(key, today string) bool {
+	if !strings.HasPrefix(key, attendanceKeyPrefix) {
+		return false
+	}
+	date := key[strings.LastIndexByte(key, ':')+1:]
+	return len(date) == len(today) && date < today
+}
+
+// GenerateNotificationKey builds the key of per-target notification state,
+// e.g. the last delivered outcome. The target URL is hashed since it usually
+// embeds credentials.
+func GenerateNotificationKey(kind, target string) string {
+	h := sha256.Sum256([]byte(target))
+	return "kv:notify:" + kind + ":" + hex.EncodeToString(h[:])
+}
 
-	return "kv:attendance:" + hashHex + ":" + date, nil
+// GenerateRunKey builds the key of a stored run record.
+func GenerateRunKey(id string) string {
+	return "kv:run:" + id
 }
 
+// RunIndexKey holds the IDs of the retained run records, newest first.
+const RunIndexKey = "kv:runs"
@@ -0,0 +1,31 @@
+package storage
+
+import "time"
+
+// Locker is implemented by stores that can hold a lock shared by every
+// process using the same backend, e.g. to keep replicas from running
+// attendance at the same time.
+type Locker interface {
+	// TryLock acquires the lock named key for owner until ttl elapses. It
+	// returns false without waiting if another owner holds an unexpired lock.
+	TryLock(key, owner string, ttl time.Duration) (bool, error)
+	// Unlock releases the lock if owner still holds it.
+	Unlock(key, owner string) error
+}
+
+// GenerateLockKey builds the key of a named lock.
+func GenerateLockKey(name string) string {
+	return "kv:lock:" + name
+}
+
+// lockEntry is a held lock.
+type lockEntry struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// availableTo reports whether owner may take the lock: it holds it already
+// or the lock has expired.
+func (l lockEntry) availableTo(owner string, now time.Time) bool {
+	return l.Owner == owner || now.After(l.Expires)
+}
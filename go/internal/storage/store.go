@@ -1,25 +1,47 @@
 package storage
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
-// Store is a minimal interface to record whether an account has attended today.
+// Store is a minimal interface to record whether an account has attended today,
+// plus a small key/value space for state that must survive between runs.
 type Store interface {
 	HasAttended(key string) (bool, error)
 	MarkAttended(key string) error
+	// Get returns the value stored under key and whether it exists.
+	Get(key string) (string, bool, error)
+	Set(key, value string) error
+	Delete(key string) error
+}
+
+// Updater is implemented by stores that can read, modify and write a value
+// without another writer in between, including other processes sharing the
+// backend.
+type Updater interface {
+	// Update replaces the value under key with the result of fn, which
+	// gets the current value and whether it exists. An error from fn
+	// leaves the value unchanged and is returned.
+	Update(key string, fn func(value string, ok bool) (string, error)) error
 }
 
 // MemoryStore is an in-memory implementation suitable for single run executions
 // such as Docker one-shot containers, QingLong tasks, or a single cloud function
 // invocation.
 type MemoryStore struct {
-	mu   sync.RWMutex
-	data map[string]struct{}
+	mu     sync.RWMutex
+	data   map[string]struct{}
+	values map[string]string
+	locks  map[string]lockEntry
 }
 
 // NewMemoryStore creates a new in-memory store.
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		data: make(map[string]struct{}),
+		data:   make(map[string]struct{}),
+		values: make(map[string]string),
+		locks:  make(map[string]lockEntry),
 	}
 }
 
@@ -31,11 +53,83 @@ func (s *MemoryStore) HasAttended(key string) (bool, error) {
 	return ok, nil
 }
 
-// MarkAttended marks the given key as attended.
+// MarkAttended marks the given key as attended, dropping the records of
+// past days so that a long running process does not accumulate them.
 func (s *MemoryStore) MarkAttended(key string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if today, err := attendanceDate(time.Now()); err == nil {
+		for k := range s.data {
+			if expiredAttendance(k, today) {
+				delete(s.data, k)
+			}
+		}
+	}
 	s.data[key] = struct{}{}
 	return nil
 }
 
+// Get returns the value stored under key.
+func (s *MemoryStore) Get(key string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.values[key]
+	return v, ok, nil
+}
+
+// Set stores value under key.
+func (s *MemoryStore) Set(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	return nil
+}
+
+// Delete removes key.
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	return nil
+}
+
+// Update replaces the value under key with the result of fn.
+func (s *MemoryStore) Update(key string, fn func(value string, ok bool) (string, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.values[key]
+	v, err := fn(old, ok)
+	if err != nil {
+		return err
+	}
+	s.values[key] = v
+	return nil
+}
+
+// TryLock acquires the lock named key for owner. Memory locks only exclude
+// runs within the same process.
+func (s *MemoryStore) TryLock(key, owner string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if l, ok := s.locks[key]; ok && !l.availableTo(owner, now) {
+		return false, nil
+	}
+	s.locks[key] = lockEntry{Owner: owner, Expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Unlock releases the lock named key if owner holds it.
+func (s *MemoryStore) Unlock(key, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.locks[key]; ok && l.Owner == owner {
+		delete(s.locks, key)
+	}
+	return nil
+}
+
+// Pinger is implemented by stores that can check their backend is reachable.
+type Pinger interface {
+	Ping() error
+}
@@ -0,0 +1,226 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// collector is an OTLP/HTTP collector recording the requests it receives.
+type collector struct {
+	*httptest.Server
+	requests chan collected
+}
+
+type collected struct {
+	header http.Header
+	body   []byte
+}
+
+func newCollector(t *testing.T, status int) *collector {
+	t.Helper()
+	c := &collector{requests: make(chan collected, 16)}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+			t.Errorf("collector got %s %s", r.Method, r.URL.Path)
+		}
+		b, _ := io.ReadAll(r.Body)
+		c.requests <- collected{header: r.Header.Clone(), body: b}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestOTLPExporterEncoding(t *testing.T) {
+	c := newCollector(t, http.StatusOK)
+	exp := NewOTLPExporter(c.URL+"/v1/traces", "skland-test", map[string]string{"X-Api-Key": "secret"})
+
+	start := time.Unix(1700000000, 123)
+	span := SpanData{
+		TraceID: TraceID{0x01, 0x02, 15: 0xff},
+		SpanID:  SpanID{0xaa, 7: 0x01},
+		Parent:  SpanID{0xbb, 7: 0x02},
+		Name:    "GET /api/game/player/binding",
+		Kind:    KindClient,
+		Start:   start,
+		End:     start.Add(1500 * time.Millisecond),
+		Attributes: []slog.Attr{
+			slog.String("url.path", "/api/game/player/binding"),
+			slog.Int("http.response.status_code", 200),
+			slog.Bool("attendance.dry_run", true),
+			slog.Float64("ratio", 0.5),
+		},
+		Events: []Event{{
+			Name:       "exception",
+			Time:       start.Add(time.Second),
+			Attributes: []slog.Attr{slog.String("exception.message", "boom")},
+		}},
+		Status:    StatusError,
+		StatusMsg: "boom",
+	}
+	root := SpanData{TraceID: span.TraceID, SpanID: SpanID{0xbb, 7: 0x02}, Name: "attendance.run", Kind: KindInternal, Start: start, End: start}
+	if err := exp.Export(context.Background(), []SpanData{span, root}); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	req := <-c.requests
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := req.header.Get("X-Api-Key"); got != "secret" {
+		t.Errorf("X-Api-Key = %q", got)
+	}
+
+	// Decode generically, so that the test checks the wire format rather
+	// than the exporter's own types.
+	var body map[string]any
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatalf("decode body: %v\n%s", err, req.body)
+	}
+	rs := body["resourceSpans"].([]any)[0].(map[string]any)
+	resource := rs["resource"].(map[string]any)["attributes"].([]any)[0].(map[string]any)
+	if resource["key"] != "service.name" || resource["value"].(map[string]any)["stringValue"] != "skland-test" {
+		t.Errorf("resource attribute = %v", resource)
+	}
+	ss := rs["scopeSpans"].([]any)[0].(map[string]any)
+	if name := ss["scope"].(map[string]any)["name"]; name != "skland-daily-attendance-go" {
+		t.Errorf("scope name = %v", name)
+	}
+	spans := ss["spans"].([]any)
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+
+	got := spans[0].(map[string]any)
+	want := map[string]any{
+		"traceId":           "010200000000000000000000000000ff",
+		"spanId":            "aa00000000000001",
+		"parentSpanId":      "bb00000000000002",
+		"name":              "GET /api/game/player/binding",
+		"kind":              float64(KindClient),
+		"startTimeUnixNano": "1700000000000000123",
+		"endTimeUnixNano":   "1700000001500000123",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("span %s = %v, want %v", k, got[k], v)
+		}
+	}
+	status := got["status"].(map[string]any)
+	if status["code"] != float64(StatusError) || status["message"] != "boom" {
+		t.Errorf("status = %v", status)
+	}
+
+	attrs := make(map[string]map[string]any)
+	for _, a := range got["attributes"].([]any) {
+		kv := a.(map[string]any)
+		attrs[kv["key"].(string)] = kv["value"].(map[string]any)
+	}
+	wantAttrs := map[string]map[string]any{
+		"url.path":                  {"stringValue": "/api/game/player/binding"},
+		"http.response.status_code": {"intValue": "200"},
+		"attendance.dry_run":        {"boolValue": true},
+		"ratio":                     {"doubleValue": 0.5},
+	}
+	for k, v := range wantAttrs {
+		for typ, val := range v {
+			if len(attrs[k]) != 1 || attrs[k][typ] != val {
+				t.Errorf("attribute %s = %v, want %s %v", k, attrs[k], typ, val)
+			}
+		}
+	}
+
+	events := got["events"].([]any)
+	ev := events[0].(map[string]any)
+	if ev["name"] != "exception" || ev["timeUnixNano"] != "1700000001000000123" {
+		t.Errorf("event = %v", ev)
+	}
+
+	// Root spans have no parentSpanId and an unset status has no code.
+	rootJSON := spans[1].(map[string]any)
+	if _, ok := rootJSON["parentSpanId"]; ok {
+		t.Errorf("root span has parentSpanId %v", rootJSON["parentSpanId"])
+	}
+	if _, ok := rootJSON["status"].(map[string]any)["code"]; ok {
+		t.Errorf("unset status has code %v", rootJSON["status"])
+	}
+}
+
+func TestOTLPExporterErrors(t *testing.T) {
+	c := newCollector(t, http.StatusServiceUnavailable)
+	exp := NewOTLPExporter(c.URL+"/v1/traces", "skland-test", nil)
+	err := exp.Export(context.Background(), []SpanData{{Name: "span"}})
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Export = %v, want a 503 error", err)
+	}
+
+	// Nothing is sent without spans.
+	if err := exp.Export(context.Background(), nil); err != nil {
+		t.Errorf("Export(nil) = %v", err)
+	}
+	if len(c.requests) != 1 {
+		t.Errorf("collector got %d requests, want 1", len(c.requests))
+	}
+}
+
+func TestProviderExportsToCollector(t *testing.T) {
+	c := newCollector(t, http.StatusOK)
+	var exportErr error
+	p := NewProvider(NewOTLPExporter(c.URL+"/v1/traces", "skland-test", nil), ProviderOptions{
+		Interval: time.Hour,
+		OnError:  func(err error) { exportErr = err },
+	})
+
+	ctx, parent := p.Start(context.Background(), "attendance.run")
+	_, child := p.Start(ctx, "attendance.account", WithAttributes(slog.Int("attendance.account", 1)))
+	child.RecordError(errors.New("登录失败"))
+	child.End()
+	parent.End()
+	parent.End()
+
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if exportErr != nil {
+		t.Fatalf("export error: %v", exportErr)
+	}
+	if len(c.requests) != 1 {
+		t.Fatalf("collector got %d requests, want one batch", len(c.requests))
+	}
+	var body otlpRequest
+	if err := json.Unmarshal((<-c.requests).body, &body); err != nil {
+		t.Fatal(err)
+	}
+	spans := body.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2 (End is idempotent)", len(spans))
+	}
+	account, run := spans[0], spans[1]
+	if account.TraceID != run.TraceID || account.ParentSpanID != run.SpanID || run.ParentSpanID != "" {
+		t.Errorf("span tree: run %+v, account %+v", run, account)
+	}
+	if account.Status.Code != StatusError || len(account.Events) != 1 || account.Events[0].Name != "exception" {
+		t.Errorf("account span = %+v, want a recorded error", account)
+	}
+}
+
+func TestStartWithoutProvider(t *testing.T) {
+	SetDefault(nil)
+	ctx := context.Background()
+	got, span := Start(ctx, "noop")
+	if got != ctx || span != nil {
+		t.Errorf("Start without provider = %v, %v", got, span)
+	}
+	// A nil span is usable.
+	span.SetAttributes(slog.String("k", "v"))
+	span.RecordError(errors.New("boom"))
+	span.End()
+}
@@ -0,0 +1,191 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Exporter sends finished spans to a backend.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// ProviderOptions configures a Provider.
+type ProviderOptions struct {
+	// BatchSize is the number of spans exported at once; ignored when
+	// Synchronous is set.
+	BatchSize int
+	// Interval is the longest a finished span waits for export.
+	Interval time.Duration
+	// Synchronous exports each span when it ends, e.g. for tests with an
+	// InMemoryExporter.
+	Synchronous bool
+	// OnError receives export errors.
+	OnError func(error)
+}
+
+const (
+	defaultBatchSize = 256
+	defaultInterval  = 5 * time.Second
+	// maxQueue bounds the spans kept while the exporter is failing.
+	maxQueue = 4096
+)
+
+// Provider starts spans and exports them in batches.
+type Provider struct {
+	exporter Exporter
+	opts     ProviderOptions
+
+	mu    sync.Mutex
+	queue []SpanData
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// NewProvider creates a provider exporting to exp.
+func NewProvider(exp Exporter, opts ProviderOptions) *Provider {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	p := &Provider{
+		exporter: exp,
+		opts:     opts,
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if opts.Synchronous {
+		close(p.stopped)
+	} else {
+		go p.loop()
+	}
+	return p
+}
+
+// Start starts a span as a child of the span in ctx.
+func (p *Provider) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	s := &Span{provider: p}
+	s.data.Name = name
+	s.data.Kind = KindInternal
+	s.data.Start = time.Now()
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.data.TraceID = parent.data.TraceID
+		s.data.Parent = parent.data.SpanID
+	} else {
+		newID(s.data.TraceID[:])
+	}
+	newID(s.data.SpanID[:])
+	for _, o := range opts {
+		o(&s.data)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (p *Provider) enqueue(d SpanData) {
+	if p.opts.Synchronous {
+		p.export(context.Background(), []SpanData{d})
+		return
+	}
+	p.mu.Lock()
+	if len(p.queue) < maxQueue {
+		p.queue = append(p.queue, d)
+	}
+	full := len(p.queue) >= p.opts.BatchSize
+	p.mu.Unlock()
+	if full {
+		select {
+		case p.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (p *Provider) loop() {
+	defer close(p.stopped)
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		case <-p.wake:
+		}
+		_ = p.ForceFlush(context.Background())
+	}
+}
+
+// ForceFlush exports every queued span.
+func (p *Provider) ForceFlush(ctx context.Context) error {
+	for {
+		p.mu.Lock()
+		n := min(len(p.queue), p.opts.BatchSize)
+		batch := p.queue[:n:n]
+		p.queue = p.queue[n:]
+		p.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		if err := p.export(ctx, batch); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *Provider) export(ctx context.Context, spans []SpanData) error {
+	err := p.exporter.Export(ctx, spans)
+	if err != nil && p.opts.OnError != nil {
+		p.opts.OnError(err)
+	}
+	return err
+}
+
+// Shutdown stops the background export and flushes the remaining spans.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() {
+		if !p.opts.Synchronous {
+			close(p.stop)
+		}
+	})
+	select {
+	case <-p.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return p.ForceFlush(ctx)
+}
+
+// InMemoryExporter keeps exported spans in memory for tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// Export records spans.
+func (e *InMemoryExporter) Export(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// Spans returns the recorded spans in export order.
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Reset drops the recorded spans.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
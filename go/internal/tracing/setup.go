@@ -0,0 +1,28 @@
+package tracing
+
+import "log/slog"
+
+// Options configures Setup.
+type Options struct {
+	// Endpoint is the OTLP/HTTP traces URL; empty disables tracing.
+	Endpoint string
+	// Headers are sent with every export, e.g. an API key.
+	Headers map[string]string
+	// ServiceName is the service.name resource attribute.
+	ServiceName string
+}
+
+// Setup installs a default provider exporting over OTLP/HTTP and returns it,
+// or returns nil when opts.Endpoint is empty. Callers should Shutdown the
+// provider before exiting.
+func Setup(opts Options) *Provider {
+	if opts.Endpoint == "" {
+		return nil
+	}
+	exp := NewOTLPExporter(opts.Endpoint, opts.ServiceName, opts.Headers)
+	p := NewProvider(exp, ProviderOptions{OnError: func(err error) {
+		slog.Warn("导出链路追踪数据失败", "err", err)
+	}})
+	SetDefault(p)
+	return p
+}
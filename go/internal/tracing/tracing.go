@@ -0,0 +1,183 @@
+// Package tracing records spans following the OpenTelemetry data model and
+// exports them over OTLP/HTTP, without depending on the OpenTelemetry SDK.
+// Without a configured provider every operation is a no-op.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// SpanKind is the OTLP span kind.
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// StatusCode is the OTLP span status code.
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// TraceID and SpanID identify spans.
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// Event is a timestamped annotation of a span.
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes []slog.Attr
+}
+
+// SpanData is a finished span, as handed to exporters.
+type SpanData struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Parent     SpanID
+	Name       string
+	Kind       SpanKind
+	Start      time.Time
+	End        time.Time
+	Attributes []slog.Attr
+	Events     []Event
+	Status     StatusCode
+	StatusMsg  string
+}
+
+// Span is an operation in progress. A nil *Span is a valid no-op span.
+type Span struct {
+	provider *Provider
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// TraceID returns the ID of the span's trace.
+func (s *Span) TraceID() TraceID {
+	if s == nil {
+		return TraceID{}
+	}
+	return s.data.TraceID
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...slog.Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+}
+
+// SetStatus sets the status of the span.
+func (s *Span) SetStatus(code StatusCode, msg string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Status = code
+	s.data.StatusMsg = msg
+}
+
+// RecordError adds an exception event and marks the span as failed. A nil
+// error is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Events = append(s.data.Events, Event{
+		Name:       "exception",
+		Time:       time.Now(),
+		Attributes: []slog.Attr{slog.String("exception.message", err.Error())},
+	})
+	s.data.Status = StatusError
+	s.data.StatusMsg = err.Error()
+}
+
+// End finishes the span and hands it to the provider. Later calls are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	s.provider.enqueue(data)
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the current span of ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// StartOption configures a span at start.
+type StartOption func(*SpanData)
+
+// WithKind sets the span kind; the default is KindInternal.
+func WithKind(k SpanKind) StartOption {
+	return func(d *SpanData) { d.Kind = k }
+}
+
+// WithAttributes sets attributes at start.
+func WithAttributes(attrs ...slog.Attr) StartOption {
+	return func(d *SpanData) { d.Attributes = append(d.Attributes, attrs...) }
+}
+
+var (
+	defaultMu       sync.RWMutex
+	defaultProvider *Provider
+)
+
+// SetDefault makes p the provider used by Start; nil disables tracing.
+func SetDefault(p *Provider) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultProvider = p
+}
+
+// Start starts a span with the default provider as a child of the span in
+// ctx. It returns ctx unchanged and a nil span when tracing is disabled.
+func Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	defaultMu.RLock()
+	p := defaultProvider
+	defaultMu.RUnlock()
+	if p == nil {
+		return ctx, nil
+	}
+	return p.Start(ctx, name, opts...)
+}
+
+func newID(b []byte) {
+	_, _ = rand.Read(b)
+}
@@ -0,0 +1,55 @@
+// Package version reports build information of the running binary.
+package version
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// Version is the release version, set at build time with
+//
+//	-ldflags "-X skland-daily-attendance-go/internal/version.Version=v1.2.3"
+//
+// and otherwise taken from the module version.
+var Version = ""
+
+// Info describes the running build.
+type Info struct {
+	Version   string     `json:"version"`
+	GoVersion string     `json:"goVersion"`
+	Revision  string     `json:"revision,omitempty"`
+	Time      *time.Time `json:"time,omitempty"`
+	Modified  bool       `json:"modified,omitempty"`
+}
+
+// Get returns the build information recorded by the Go toolchain.
+func Get() Info {
+	info := Info{Version: Version}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		if info.Version == "" {
+			info.Version = "unknown"
+		}
+		return info
+	}
+	info.GoVersion = bi.GoVersion
+	if info.Version == "" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			if t, err := time.Parse(time.RFC3339, s.Value); err == nil {
+				info.Time = &t
+			}
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	if info.Version == "" {
+		info.Version = "(devel)"
+	}
+	return info
+}